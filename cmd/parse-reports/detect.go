@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tekkamanendless/cboc-tools/csvloader"
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"github.com/tekkamanendless/cboc-tools/fsfreport"
+	"github.com/tekkamanendless/cboc-tools/mobiusreport"
+)
+
+// sampleSize is how much of each file is read to work out what it is.
+const sampleSize = 64 * 1024
+
+// fiscalYearStartMonth is the first month of the fiscal year, which is used to turn a Mobius accounting period into a month.
+//
+// This is set by "-fiscal-year-start-month", which must match the flag of the same name given to the "render" command.
+var fiscalYearStartMonth = 7
+
+// detectFiles works out what kind of report each file is, no matter what it is named.
+//
+// Each directory is searched for reports, but not its subdirectories.  Anything that is not a report is skipped.
+// If the same report shows up more than once, then the CSV wins and the others are its fallbacks.
+func detectFiles(paths []string) ([]reportFile, error) {
+	var filenames []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			filenames = append(filenames, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			filenames = append(filenames, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	var files []reportFile
+	byKey := map[string]*reportFile{}
+	var keys []string
+	for _, filename := range filenames {
+		file, err := detectFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", filename, err)
+		}
+		if file == nil {
+			fmt.Printf("Skipping %s: not a report that can be loaded.\n", filename)
+			continue
+		}
+		// Each division has its own Mobius report, so one that is not for any division cannot be told apart from the others.
+		if isMobiusReport(file.ReportType) && file.division() == "" {
+			file.Division = namedDivision(filename, file.ReportType)
+			if file.Division == "" {
+				return nil, fmt.Errorf("could not tell which division %s is for; name it %s.<division>.%s, or check -mobius-report-file-pattern and -divisions", filename, file.ReportType, file.Format)
+			}
+		}
+		fmt.Printf("Found %s: %s (%s)\n", filename, file.ReportType, file.Format)
+
+		existing, ok := byKey[file.key()]
+		if !ok {
+			byKey[file.key()] = file
+			keys = append(keys, file.key())
+			continue
+		}
+		if file.Format == existing.Format {
+			fmt.Printf("Skipping %s: it is the same report as %s.\n", filename, existing.Filename)
+			continue
+		}
+		if file.Format == formatCSV {
+			file.Fallback = existing
+			byKey[file.key()] = file
+		} else {
+			last := existing
+			for last.Fallback != nil {
+				last = last.Fallback
+			}
+			last.Fallback = file
+		}
+	}
+	for _, key := range keys {
+		files = append(files, *byKey[key])
+	}
+
+	// The FSF reports go first, just like when the files are found by name.
+	sort.SliceStable(files, func(i, j int) bool {
+		return reportOrder(files[i].ReportType) < reportOrder(files[j].ReportType)
+	})
+	return files, nil
+}
+
+// isMobiusReport returns true if the report is one of the Mobius reports, which are downloaded one division at a time.
+func isMobiusReport(reportType string) bool {
+	switch reportType {
+	case reportMobiusDGL060, reportMobiusDGL114, reportMobiusDGL115:
+		return true
+	}
+	return false
+}
+
+// reportOrder is the order in which the reports are loaded.
+func reportOrder(reportType string) int {
+	for i, t := range []string{reportFSFExpenditureSummary, reportFSFProgramSummary, reportFSFDetailedActivity, reportMobiusDGL060, reportMobiusDGL114, reportMobiusDGL115} {
+		if t == reportType {
+			return i
+		}
+	}
+	return -1
+}
+
+// detectFile works out what kind of report a file is from its contents.
+//
+// CSV files are recognized by their headers, PDFs by their column headings, and Mobius print reports by the report
+// name in their page headers.  If the file is not any of those, then this returns nil.
+func detectFile(filename string) (*reportFile, error) {
+	fileHandle, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fileHandle.Close()
+
+	sample := make([]byte, sampleSize)
+	n, err := io.ReadFull(fileHandle, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	sample = sample[:n]
+
+	if bytes.HasPrefix(sample, []byte("%PDF-")) {
+		return detectPDF(filename, fileHandle)
+	}
+	if file := detectCSV(filename, sample); file != nil {
+		return file, nil
+	}
+	return detectPrintReport(filename, sample), nil
+}
+
+// detectCSV checks the header of the file against the columns that each report should have.
+func detectCSV(filename string, sample []byte) *reportFile {
+	file := &reportFile{Filename: filename, Format: formatCSV}
+	switch {
+	case hasColumns[databasemodel.FSFOperatingUnitExpenditureSummary](sample):
+		file.ReportType = reportFSFExpenditureSummary
+	case hasColumns[databasemodel.FSFOperatingUnitProgramSummary](sample):
+		file.ReportType = reportFSFProgramSummary
+	case hasColumns[databasemodel.FSFDetailedActivity](sample):
+		file.ReportType = reportFSFDetailedActivity
+	case hasColumns[databasemodel.MobiusDGL060](sample):
+		file.ReportType = reportMobiusDGL060
+		if record, ok := firstCSVRecord[databasemodel.MobiusDGL060](sample); ok {
+			file.detectedDivision = divisionFromDepartment(record.DepartmentID)
+			file.Year, file.Month = asOfPeriod(record.AsOfDate)
+		}
+	case hasColumns[databasemodel.MobiusDGL114](sample):
+		file.ReportType = reportMobiusDGL114
+		if record, ok := firstCSVRecord[databasemodel.MobiusDGL114](sample); ok {
+			file.detectedDivision = divisionFromDepartment(record.DepartmentID)
+			file.Year, file.Month = asOfPeriod(record.AsOfDate)
+		}
+	case hasColumns[databasemodel.MobiusDGL115](sample):
+		file.ReportType = reportMobiusDGL115
+		if record, ok := firstCSVRecord[databasemodel.MobiusDGL115](sample); ok {
+			file.detectedDivision = divisionFromDepartment(record.DepartmentID)
+			file.Year, file.Month = accountingPeriodMonth(record.FiscalYear, record.AccountPeriod)
+		}
+	default:
+		return nil
+	}
+	return file
+}
+
+// hasColumns returns true if the CSV has every column of the given model.
+func hasColumns[T any](sample []byte) bool {
+	reader, err := csvloader.NewReader[T](bytes.NewReader(sample))
+	if err != nil {
+		return false
+	}
+	return len(reader.Headers) > 0 && len(reader.MissingColumns) == 0
+}
+
+// firstCSVRecord returns the first row of the CSV that can be loaded.
+func firstCSVRecord[T any](sample []byte) (T, bool) {
+	var record T
+	reader, err := csvloader.NewReader[T](bytes.NewReader(sample))
+	if err != nil {
+		return record, false
+	}
+	record, _, err = reader.Read()
+	return record, err == nil
+}
+
+// detectPDF tries each of the FSF reports that come as a PDF.
+//
+// A PDF only counts if it has the column headings of the report.  One that does but that cannot be loaded (such as
+// one whose rows do not add up) still counts; the problem will be reported when it is loaded.  A PDF that cannot be
+// read at all is skipped.
+func detectPDF(filename string, fileHandle *os.File) (*reportFile, error) {
+	info, err := fileHandle.Stat()
+	if err != nil {
+		return nil, err
+	}
+	file := &reportFile{Filename: filename, Format: formatPDF}
+
+	programSummary, err := fsfreport.ParseOperatingUnitProgramSummary(fileHandle, info.Size())
+	if err == nil || errors.Is(err, fsfreport.ErrIncomplete) {
+		file.ReportType = reportFSFProgramSummary
+		if err == nil && len(programSummary.Records) > 0 {
+			record := programSummary.Records[0]
+			file.Year, file.Month = fsfPeriod(record.FiscalYear, record.FiscalMonth, 0, 0)
+		}
+		return file, nil
+	}
+	if !errors.Is(err, fsfreport.ErrWrongReport) {
+		fmt.Printf("Warning: %s: %v\n", filename, err)
+		return nil, nil
+	}
+	expenditureSummary, err := fsfreport.ParseOperatingUnitExpenditureSummary(fileHandle, info.Size())
+	if err == nil || errors.Is(err, fsfreport.ErrIncomplete) {
+		file.ReportType = reportFSFExpenditureSummary
+		if err == nil && len(expenditureSummary.Records) > 0 {
+			record := expenditureSummary.Records[0]
+			file.Year, file.Month = fsfPeriod(record.FiscalYear, record.FiscalMonth, 0, 0)
+		}
+		return file, nil
+	}
+	if !errors.Is(err, fsfreport.ErrWrongReport) {
+		fmt.Printf("Warning: %s: %v\n", filename, err)
+	}
+	return nil, nil
+}
+
+// detectPrintReport tries each of the Mobius print reports against the start of the file.
+//
+// A file only counts if one of the reports can be read from it; anything else is skipped.
+func detectPrintReport(filename string, sample []byte) *reportFile {
+	file := &reportFile{Filename: filename, Format: formatPrint}
+
+	if result, err := mobiusreport.ParseDGL060(bytes.NewReader(sample)); err == nil {
+		file.ReportType = reportMobiusDGL060
+		if len(result.Records) > 0 {
+			record := result.Records[0]
+			file.detectedDivision = divisionFromDepartment(record.DepartmentID)
+			file.Year, file.Month = asOfPeriod(record.AsOfDate)
+		}
+		return file
+	} else if !errors.Is(err, mobiusreport.ErrWrongReport) {
+		fmt.Printf("Warning: %s: %v\n", filename, err)
+		return nil
+	}
+	if result, err := mobiusreport.ParseDGL114(bytes.NewReader(sample)); err == nil {
+		file.ReportType = reportMobiusDGL114
+		if len(result.Records) > 0 {
+			record := result.Records[0]
+			file.detectedDivision = divisionFromDepartment(record.DepartmentID)
+			file.Year, file.Month = asOfPeriod(record.AsOfDate)
+		}
+		return file
+	} else if !errors.Is(err, mobiusreport.ErrWrongReport) {
+		fmt.Printf("Warning: %s: %v\n", filename, err)
+		return nil
+	}
+	if result, err := mobiusreport.ParseDGL115(bytes.NewReader(sample)); err == nil {
+		file.ReportType = reportMobiusDGL115
+		if len(result.Records) > 0 {
+			record := result.Records[0]
+			file.detectedDivision = divisionFromDepartment(record.DepartmentID)
+			file.Year, file.Month = accountingPeriodMonth(record.FiscalYear, record.AccountPeriod)
+		}
+		return file
+	} else if !errors.Is(err, mobiusreport.ErrWrongReport) {
+		fmt.Printf("Warning: %s: %v\n", filename, err)
+	}
+	return nil
+}
+
+// accountingPeriodMonth returns the calendar year and month for an accounting period of a fiscal year.
+//
+// Period 1 is the first month of the fiscal year, which ends in the calendar year that it is named for.
+func accountingPeriodMonth(fiscalYear int, period int) (int, int) {
+	if fiscalYear == 0 || period < 1 || period > 12 {
+		return 0, 0
+	}
+	month := (fiscalYearStartMonth-1+period-1)%12 + 1
+	year := fiscalYear
+	if month >= fiscalYearStartMonth && fiscalYearStartMonth > 1 {
+		year--
+	}
+	return year, month
+}
+
+// asOfGraceDays is how far into a month an "as of" date can be and still be for the end of the month before.
+//
+// The "report" command downloads the first Mobius date file after the end of the month when there is not one for the
+// last day, and that can be a few days later if the month ends on a weekend or a holiday.
+const asOfGraceDays = 7
+
+// asOfPeriod returns the calendar year and month that a Mobius report with the given "as of" date is for.
+func asOfPeriod(asOfDate time.Time) (int, int) {
+	if asOfDate.IsZero() {
+		return 0, 0
+	}
+	date := asOfDate.AddDate(0, 0, -asOfGraceDays)
+	return date.Year(), int(date.Month())
+}
+
+// fiscalPeriod returns the fiscal year and accounting period for a calendar year and month.
+//
+// This is the opposite of accountingPeriodMonth.
+func fiscalPeriod(year int, month int) (int, int) {
+	period := (month-fiscalYearStartMonth+12)%12 + 1
+	fiscalYear := year
+	if month >= fiscalYearStartMonth && fiscalYearStartMonth > 1 {
+		fiscalYear++
+	}
+	return fiscalYear, period
+}
+
+// detectedPeriod returns the period that the files are for, according to their contents.
+//
+// If none of the files say, then this returns zeros; if they do not agree, then that is an error.
+func detectedPeriod(files []reportFile) (int, int, error) {
+	periods := map[string]bool{}
+	var year, month int
+	for _, file := range files {
+		for f := &file; f != nil; f = f.Fallback {
+			if f.Year == 0 || f.Month == 0 {
+				continue
+			}
+			year, month = f.Year, f.Month
+			periods[fmt.Sprintf("%d-%02d", f.Year, f.Month)] = true
+		}
+	}
+	if len(periods) > 1 {
+		var list []string
+		for period := range periods {
+			list = append(list, period)
+		}
+		sort.Strings(list)
+		return 0, 0, fmt.Errorf("the files are for different periods (%s); use -target-year and -target-month to pick one", strings.Join(list, ", "))
+	}
+	return year, month, nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectFile(t *testing.T) {
+	rows := []struct {
+		filename   string
+		reportType string
+		format     string
+		division   string
+		year       int
+		month      int
+	}{
+		{filename: "../../fsfreport/testdata/fsf.operating-unit-expenditure-summary.pdf", reportType: reportFSFExpenditureSummary, format: formatPDF, year: 2024, month: 9},
+		{filename: "../../fsfreport/testdata/fsf.operating-unit-program-summary.pdf", reportType: reportFSFProgramSummary, format: formatPDF, year: 2024, month: 9},
+		{filename: "../../mobiusreport/testdata/DGL060.txt", reportType: reportMobiusDGL060, format: formatPrint, division: "33", year: 2024, month: 9},
+		{filename: "../../mobiusreport/testdata/DGL114.txt", reportType: reportMobiusDGL114, format: formatPrint, division: "33", year: 2024, month: 9},
+		{filename: "../../mobiusreport/testdata/DGL115.txt", reportType: reportMobiusDGL115, format: formatPrint, division: "33", year: 2024, month: 9},
+	}
+	for _, row := range rows {
+		t.Run(filepath.Base(row.filename), func(t *testing.T) {
+			file, err := detectFile(row.filename)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if file == nil {
+				t.Fatalf("The file was not recognized.")
+			}
+			if file.ReportType != row.reportType || file.Format != row.format {
+				t.Errorf("Expected %s (%s); got %s (%s).", row.reportType, row.format, file.ReportType, file.Format)
+			}
+			if file.division() != row.division {
+				t.Errorf("Expected division %q; got %q.", row.division, file.division())
+			}
+			if file.Year != row.year || file.Month != row.month {
+				t.Errorf("Expected %d-%02d; got %d-%02d.", row.year, row.month, file.Year, file.Month)
+			}
+		})
+	}
+}
+
+func TestDetectFileNotAReport(t *testing.T) {
+	directory := t.TempDir()
+	files := map[string]string{
+		// This looks like a PDF, but it cannot be read as one.
+		"notes.pdf": "%PDF-1.4\nnot really a PDF\n",
+		// This mentions a report, but it is not one.
+		"notes.txt": "Remember to download DGL060 and DGL115.\nThe TOTALS are in the report.\n",
+		"empty.txt": "",
+	}
+	for name, contents := range files {
+		filename := filepath.Join(directory, name)
+		err := os.WriteFile(filename, []byte(contents), 0644)
+		if err != nil {
+			t.Fatalf("Could not write %s: %v", name, err)
+		}
+		file, err := detectFile(filename)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if file != nil {
+			t.Errorf("%s: expected it to be skipped; got %s (%s).", name, file.ReportType, file.Format)
+		}
+	}
+}
+
+func TestAsOfPeriod(t *testing.T) {
+	rows := []struct {
+		asOfDate time.Time
+		year     int
+		month    int
+	}{
+		{asOfDate: time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC), year: 2025, month: 6},
+		// The first date file after the end of June is still for June.
+		{asOfDate: time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), year: 2025, month: 6},
+		{asOfDate: time.Date(2025, 7, 7, 0, 0, 0, 0, time.UTC), year: 2025, month: 6},
+		{asOfDate: time.Date(2025, 7, 8, 0, 0, 0, 0, time.UTC), year: 2025, month: 7},
+		{asOfDate: time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), year: 2024, month: 12},
+		{asOfDate: time.Time{}, year: 0, month: 0},
+	}
+	for _, row := range rows {
+		year, month := asOfPeriod(row.asOfDate)
+		if year != row.year || month != row.month {
+			t.Errorf("%s: expected %d-%02d; got %d-%02d.", row.asOfDate.Format("2006-01-02"), row.year, row.month, year, month)
+		}
+	}
+}
+
+func TestDivisionFromDepartment(t *testing.T) {
+	defer func(pattern string, divisions []string) {
+		mobiusReportFilePattern, mobiusDivisions = pattern, divisions
+	}(mobiusReportFilePattern, mobiusDivisions)
+
+	rows := []struct {
+		pattern      string
+		divisions    []string
+		departmentID string
+		division     string
+	}{
+		{pattern: "95%s00", divisions: []string{"33", "51", "56", "60"}, departmentID: "95330000", division: "33"},
+		{pattern: "95%s00", divisions: []string{"33", "51", "56", "60"}, departmentID: "95600000", division: "60"},
+		{pattern: "95%s00", divisions: []string{"33", "51", "56", "60"}, departmentID: "95990000", division: ""},
+		// Another district numbers its departments differently.
+		{pattern: "31%s", divisions: []string{"10", "20"}, departmentID: "31200000", division: "20"},
+		{pattern: "31%s", divisions: []string{"10", "20"}, departmentID: "95330000", division: ""},
+	}
+	for _, row := range rows {
+		mobiusReportFilePattern, mobiusDivisions = row.pattern, row.divisions
+		division := divisionFromDepartment(row.departmentID)
+		if division != row.division {
+			t.Errorf("%s %s: expected %q; got %q.", row.pattern, row.departmentID, row.division, division)
+		}
+	}
+}
+
+func TestDetectFilesDivisions(t *testing.T) {
+	contents, err := os.ReadFile("../../mobiusreport/testdata/DGL060.txt")
+	if err != nil {
+		t.Fatalf("Could not read the report: %v", err)
+	}
+	header := "rpt_asof_date,dept_id,dept_desc,fy,fund,appr,type,appr_descr,end_date,available_funds,encumbrances,curr_yr_expen,prior_yr_expen,remain_spend_auth\n"
+
+	// The same report for two divisions must not be mistaken for the same file.
+	directory := t.TempDir()
+	files := map[string]string{
+		"report.txt":     string(contents),
+		"report (1).txt": strings.ReplaceAll(string(contents), "95330000", "95510000"),
+		// This has no rows to tell, but its name does.
+		"mobius.DGL060.56.csv": header,
+	}
+	for name, contents := range files {
+		err := os.WriteFile(filepath.Join(directory, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatalf("Could not write %s: %v", name, err)
+		}
+	}
+	found, err := detectFiles([]string{directory})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var divisions []string
+	for _, file := range found {
+		divisions = append(divisions, file.division())
+	}
+	sort.Strings(divisions)
+	if strings.Join(divisions, ",") != "33,51,56" {
+		t.Errorf("Expected divisions 33, 51, and 56; got %v.", divisions)
+	}
+
+	// A report with nothing to say which division it is for is an error, rather than being merged with another one.
+	err = os.WriteFile(filepath.Join(directory, "report.csv"), []byte(header), 0644)
+	if err != nil {
+		t.Fatalf("Could not write the file: %v", err)
+	}
+	_, err = detectFiles([]string{directory})
+	if err == nil {
+		t.Errorf("Expected an error for a report without a division.")
+	}
+}
+
+func TestNamedDivision(t *testing.T) {
+	rows := []struct {
+		filename   string
+		reportType string
+		division   string
+	}{
+		{filename: "downloads/mobius.DGL060.33.csv", reportType: reportMobiusDGL060, division: "33"},
+		{filename: "mobius.DGL115.60.txt", reportType: reportMobiusDGL115, division: "60"},
+		{filename: "mobius.DGL060.csv", reportType: reportMobiusDGL060, division: ""},
+		{filename: "mobius.DGL114.33.csv", reportType: reportMobiusDGL060, division: ""},
+		{filename: "report.txt", reportType: reportMobiusDGL060, division: ""},
+	}
+	for _, row := range rows {
+		division := namedDivision(row.filename, row.reportType)
+		if division != row.division {
+			t.Errorf("%s: expected %q; got %q.", row.filename, row.division, division)
+		}
+	}
+}
+
+func TestAccountingPeriodMonth(t *testing.T) {
+	defer func(startMonth int) {
+		fiscalYearStartMonth = startMonth
+	}(fiscalYearStartMonth)
+
+	rows := []struct {
+		startMonth int
+		fiscalYear int
+		period     int
+		year       int
+		month      int
+	}{
+		{startMonth: 7, fiscalYear: 2025, period: 1, year: 2024, month: 7},
+		{startMonth: 7, fiscalYear: 2025, period: 12, year: 2025, month: 6},
+		{startMonth: 10, fiscalYear: 2025, period: 1, year: 2024, month: 10},
+		{startMonth: 10, fiscalYear: 2025, period: 4, year: 2025, month: 1},
+		{startMonth: 1, fiscalYear: 2025, period: 1, year: 2025, month: 1},
+		{startMonth: 1, fiscalYear: 2025, period: 12, year: 2025, month: 12},
+	}
+	for _, row := range rows {
+		fiscalYearStartMonth = row.startMonth
+		year, month := accountingPeriodMonth(row.fiscalYear, row.period)
+		if year != row.year || month != row.month {
+			t.Errorf("Start month %d, FY%d period %d: expected %d-%02d; got %d-%02d.", row.startMonth, row.fiscalYear, row.period, row.year, row.month, year, month)
+		}
+		fiscalYear, period := fiscalPeriod(row.year, row.month)
+		if fiscalYear != row.fiscalYear || period != row.period {
+			t.Errorf("Start month %d, %d-%02d: expected FY%d period %d; got FY%d period %d.", row.startMonth, row.year, row.month, row.fiscalYear, row.period, fiscalYear, period)
+		}
+	}
+}
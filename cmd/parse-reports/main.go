@@ -1,600 +1,535 @@
 package main
 
 import (
-	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/tekkamanendless/cboc-tools/csvloader"
 	"github.com/tekkamanendless/cboc-tools/database"
 	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"github.com/tekkamanendless/cboc-tools/fsfreport"
+	"github.com/tekkamanendless/cboc-tools/mobiusreport"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
+// Period is the contents of the "period.json" file that the "report" command saves alongside the downloaded reports.
+type Period struct {
+	TargetYear  int `json:"targetYear"`
+	TargetMonth int `json:"targetMonth"`
+}
+
 func main() {
 	var baseDirectory string
 	var databaseFile string
+	var targetYear int
+	var targetMonth int
+	var strict bool
+	var rejectsFile string
+	var divisions string
 	flag.StringVar(&baseDirectory, "base-directory", "", "The location to save the results.")
 	flag.StringVar(&databaseFile, "database-file", "", "The database file.")
+	flag.IntVar(&targetYear, "target-year", 0, "The target year that the reports were downloaded for.")
+	flag.IntVar(&targetMonth, "target-month", 0, "The target month that the reports were downloaded for.")
+	flag.BoolVar(&strict, "strict", false, "Fail if a file is missing any of its required columns, instead of skipping it.")
+	flag.StringVar(&rejectsFile, "rejects-file", "", "The CSV file to write the rejected rows to, if there are any.  If not set, then \"rejects.csv\" in the base directory (or next to the database file) is used.")
+	flag.StringVar(&mobiusReportFilePattern, "mobius-report-file-pattern", mobiusReportFilePattern, "This turns a division into the name of its report file in Mobius; the \"%s\" is the division.  This must match the profile that the \"report\" command used.")
+	flag.StringVar(&divisions, "divisions", strings.Join(mobiusDivisions, ","), "The divisions that the Mobius reports may be for, separated by commas.  This must match the profile that the \"report\" command used.")
+	flag.IntVar(&fiscalYearStartMonth, "fiscal-year-start-month", fiscalYearStartMonth, "The first month of the fiscal year.  This must match the flag of the same name given to the \"render\" command.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] [file or directory...]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "With no files, the reports in the base directory are found by the names that the \"report\" command saves them under.\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Otherwise, each file (and each file in each directory) is recognized by its contents, no matter what it is named.\n\n")
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 
-	db, err := database.New("file:" + databaseFile)
-	if err != nil {
-		panic(err)
+	if fiscalYearStartMonth < 1 || fiscalYearStartMonth > 12 {
+		panic(fmt.Errorf("the fiscal year start month must be from 1 to 12: %d", fiscalYearStartMonth))
+	}
+	if strings.Count(mobiusReportFilePattern, "%s") != 1 {
+		panic(fmt.Errorf("the Mobius report file pattern must have exactly one %%s: %q", mobiusReportFilePattern))
+	}
+	mobiusDivisions = nil
+	for _, division := range strings.Split(divisions, ",") {
+		if division = strings.TrimSpace(division); division != "" {
+			mobiusDivisions = append(mobiusDivisions, division)
+		}
 	}
 
-	err = databasemodel.Apply(db)
+	// With no arguments, the reports are found by the names that the "report" command saves them under.
+	var files []reportFile
+	var err error
+	var periodDirectories []string
+	if flag.NArg() == 0 {
+		files, err = namedFiles(baseDirectory)
+		periodDirectories = append(periodDirectories, baseDirectory)
+	} else {
+		files, err = detectFiles(flag.Args())
+		if baseDirectory != "" {
+			periodDirectories = append(periodDirectories, baseDirectory)
+		}
+		for _, path := range flag.Args() {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				periodDirectories = append(periodDirectories, path)
+			}
+		}
+	}
 	if err != nil {
 		panic(err)
 	}
 
-	{
-		filename := baseDirectory + string(filepath.Separator) + "fsf.operating-unit-expenditure-summary.csv"
-		fmt.Printf("filename: %s\n", filename)
-
-		if _, err := os.Stat(filename); err != nil && os.IsNotExist(err) {
-			fmt.Printf("File not found: %s\n", filename)
-			panic(err)
-		} else {
-			fileHandle, err := os.Open(filename)
-			if err != nil {
+	// If the period was not given, then use the one that the "report" command saved when it downloaded the files.
+	for _, directory := range periodDirectories {
+		if targetYear != 0 && targetMonth != 0 {
+			break
+		}
+		filename := filepath.Join(directory, "period.json")
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			if !os.IsNotExist(err) {
 				panic(err)
 			}
-			csvReader := csv.NewReader(fileHandle)
-			rows, err := csvReader.ReadAll()
+		} else {
+			var period Period
+			err = json.Unmarshal(contents, &period)
 			if err != nil {
-				panic(err)
+				panic(fmt.Errorf("could not parse %s: %w", filename, err))
 			}
+			if targetYear == 0 {
+				targetYear = period.TargetYear
+			}
+			if targetMonth == 0 {
+				targetMonth = period.TargetMonth
+			}
+		}
+	}
+	// Failing that, the Mobius reports say what they are for.
+	if targetYear == 0 || targetMonth == 0 {
+		year, month, err := detectedPeriod(files)
+		if err != nil {
+			panic(err)
+		}
+		if targetYear == 0 {
+			targetYear = year
+		}
+		if targetMonth == 0 {
+			targetMonth = month
+		}
+	}
+	// These defaults match the ones used by the "report" command.
+	if targetYear == 0 {
+		targetDate := time.Now().AddDate(0, -1, 0)
+		targetYear = targetDate.Year()
+	}
+	if targetMonth == 0 {
+		targetDate := time.Now().AddDate(0, -1, 0)
+		targetMonth = int(targetDate.Month())
+	}
+	fmt.Printf("Target period: %d-%02d\n", targetYear, targetMonth)
 
-			if len(rows) == 0 {
-				fmt.Printf("No rows found in the CSV file.\n")
-			} else {
-				fmt.Printf("Rows: %d\n", len(rows))
-
-				header := rows[0]
-				rows = rows[1:]
-				rows = deduplicate(rows)
-
-				headerMap := map[string]int{}
-				for i, column := range header {
-					column = strings.ToLower(column)
-					column = strings.TrimSpace(column)
-					headerMap[column] = i
-				}
+	db, err := database.New("file:" + databaseFile)
+	if err != nil {
+		panic(err)
+	}
 
-				var records []databasemodel.FSFOperatingUnitExpenditureSummary
-				for r, row := range rows {
-					for c := range row {
-						row[c] = strings.TrimSpace(row[c])
-					}
-					record := databasemodel.FSFOperatingUnitExpenditureSummary{
-						District:                 row[headerMap["district"]],
-						Division:                 row[headerMap["div"]],
-						RecordType:               row[headerMap["recordtype"]],
-						SubType:                  row[headerMap["subtype"]],
-						OperatingUnit:            row[headerMap["operatingunit"]],
-						OperatingUnitDescription: row[headerMap["descr"]],
-					}
-					if row[headerMap["budgetamt"]] != "" {
-						v, err := strconv.ParseFloat(row[headerMap["budgetamt"]], 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing budgetamt: %v\n", r+1, err)
-							continue
-						}
-						record.BudgetedAmount = v
-					}
-					if row[headerMap["encumberedamt"]] != "" {
-						v, err := strconv.ParseFloat(row[headerMap["encumberedamt"]], 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing encumberedamt: %v\n", r+1, err)
-							continue
-						}
-						record.EncumberedAmount = v
-					}
-					if row[headerMap["expendedamt"]] != "" {
-						v, err := strconv.ParseFloat(row[headerMap["expendedamt"]], 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing expendedamt: %v\n", r+1, err)
-							continue
-						}
-						record.ExpendedAmount = v
-					}
-					records = append(records, record)
-				}
+	err = databasemodel.Apply(db)
+	if err != nil {
+		panic(err)
+	}
 
-				err := db.CreateInBatches(records, 100).Error
-				if err != nil {
-					panic(err)
-				}
-			}
+	if rejectsFile == "" {
+		directory := baseDirectory
+		if directory == "" {
+			directory = filepath.Dir(databaseFile)
 		}
+		rejectsFile = filepath.Join(directory, "rejects.csv")
 	}
+	l := &loader{
+		db:          db,
+		targetYear:  targetYear,
+		targetMonth: targetMonth,
+		strict:      strict,
+		rejects:     &rejectLog{},
+	}
+	// The rejects are written even if a file fails to load, since that is when they are needed the most.
+	defer func() {
+		l.rejects.printSummary()
+		err := l.rejects.write(rejectsFile)
+		if err != nil {
+			fmt.Printf("Could not write %s: %v\n", rejectsFile, err)
+			return
+		}
+		if len(l.rejects.entries) == 0 {
+			fmt.Printf("Rejects: none\n")
+			return
+		}
+		fmt.Printf("Rejects: %s\n", rejectsFile)
+	}()
 
-	{
-		filename := baseDirectory + string(filepath.Separator) + "fsf.operating-unit-program-summary.csv"
-		fmt.Printf("filename: %s\n", filename)
-
-		if _, err := os.Stat(filename); err != nil && os.IsNotExist(err) {
-			fmt.Printf("File not found: %s\n", filename)
+	for _, file := range files {
+		err := loadFile(l, file)
+		if err != nil {
 			panic(err)
-		} else {
-			fileHandle, err := os.Open(filename)
-			if err != nil {
-				panic(err)
-			}
-			csvReader := csv.NewReader(fileHandle)
-			rows, err := csvReader.ReadAll()
-			if err != nil {
-				panic(err)
-			}
-
-			if len(rows) == 0 {
-				fmt.Printf("No rows found in the CSV file.\n")
-			} else {
-				fmt.Printf("Rows: %d\n", len(rows))
+		}
+	}
+}
 
-				header := rows[0]
-				rows = rows[1:]
-				rows = deduplicate(rows)
+// insertBatchSize is how many records are held in memory before they are written to the database.
+const insertBatchSize = 5000
 
-				headerMap := map[string]int{}
-				for i, column := range header {
-					column = strings.ToLower(column)
-					headerMap[column] = i
-				}
+// loader holds what every report needs in order to be loaded.
+type loader struct {
+	db          *gorm.DB
+	targetYear  int
+	targetMonth int
+	strict      bool // If this is set, then a file that is missing a required column is an error.
+	rejects     *rejectLog
+}
 
-				var records []databasemodel.FSFOperatingUnitProgramSummary
-				for r, row := range rows {
-					record := databasemodel.FSFOperatingUnitProgramSummary{
-						District:                 row[headerMap["district"]],
-						Division:                 row[headerMap["div"]],
-						RecordType:               row[headerMap["recordtype"]],
-						OperatingUnit:            row[headerMap["operatingunit"]],
-						OperatingUnitDescription: row[headerMap["operatingunitdesc"]],
-						ProgramCode:              row[headerMap["programcode"]],
-						ProgramCodeDescription:   row[headerMap["programcodedesc"]],
-					}
-					if row[headerMap["budgetamt"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["budgetamt"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing budgetamt: %v\n", r+1, err)
-							continue
-						}
-						record.BudgetedAmount = v
-					}
-					if row[headerMap["encumberedamt"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["encumberedamt"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing encumberedamt: %v\n", r+1, err)
-							continue
-						}
-						record.EncumberedAmount = v
-					}
-					if row[headerMap["expendedamt"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["expendedamt"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing expendedamt: %v\n", r+1, err)
-							continue
-						}
-						record.ExpendedAmount = v
-					}
-
-					records = append(records, record)
-				}
+// loadReport reads a CSV file and replaces its import in the database.
+//
+// The file is streamed into the database in batches, so only one batch of records is in memory at a time.
+// The prepare function fills in anything that does not come from the columns, such as the division from the filename.
+func loadReport[T any](l *loader, file reportFile, prepare func(*T), setImportID func(*T, uint)) error {
+	filename := file.Filename
+	fileHandle, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
 
-				err := db.CreateInBatches(records, 100).Error
-				if err != nil {
-					panic(err)
-				}
-			}
-		}
+	fileInfo, err := fileHandle.Stat()
+	if err != nil {
+		return err
 	}
+	progress := &progressReader{reader: fileHandle, total: fileInfo.Size()}
 
-	files, err := filepath.Glob(baseDirectory + string(filepath.Separator) + "mobius.DGL060.*.csv")
+	reader, err := csvloader.NewReader[T](progress)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("could not read %s: %w", filename, err)
+	}
+	// A column that is missing (or has been renamed) would load as zeros, so the file is not loaded at all.
+	if len(reader.Headers) == 0 {
+		return fmt.Errorf("the file is empty: %w", errUnusable)
+	}
+	if len(reader.MissingColumns) > 0 {
+		l.rejects.addRejects(filename, reader.MissingColumns, nil)
+		return fmt.Errorf("missing columns: %s: %w", strings.Join(reader.MissingColumns, ", "), errUnusable)
 	}
-	for _, filename := range files {
-		division := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(filename), "mobius.DGL060."), ".csv")
-		fmt.Printf("filename: %s\n", filename)
 
-		if _, err := os.Stat(filename); err != nil && os.IsNotExist(err) {
-			fmt.Printf("File not found: %s\n", filename)
-			panic(err)
-		} else {
-			fileHandle, err := os.Open(filename)
-			if err != nil {
-				panic(err)
+	var count int
+	err = replaceImport(l.db, file, l.targetYear, l.targetMonth, func(insert func([]T) error) error {
+		batch := make([]T, 0, insertBatchSize)
+		for {
+			record, _, err := reader.Read()
+			if err == io.EOF {
+				break
 			}
-			csvReader := csv.NewReader(fileHandle)
-			rows, err := csvReader.ReadAll()
 			if err != nil {
-				panic(err)
+				return err
 			}
-
-			if len(rows) == 0 {
-				fmt.Printf("No rows found in the CSV file.\n")
-			} else {
-				fmt.Printf("Rows: %d\n", len(rows))
-
-				header := rows[0]
-				rows = rows[1:]
-				rows = deduplicate(rows)
-
-				headerMap := map[string]int{}
-				for i, column := range header {
-					column = strings.ToLower(column)
-					headerMap[column] = i
-				}
-
-				var records []databasemodel.MobiusDGL060
-				for r, row := range rows {
-					row = processFormulas(row)
-
-					record := databasemodel.MobiusDGL060{
-						Division:                 division,
-						DepartmentID:             row[headerMap["dept_id"]],
-						DepartmentDescription:    row[headerMap["dept_desc"]],
-						Fund:                     row[headerMap["fund"]],
-						Appropriation:            row[headerMap["appr"]],
-						AppropriationType:        row[headerMap["type"]],
-						AppropriationDescription: row[headerMap["appr_descr"]],
-					}
-					{
-						v, err := strconv.ParseInt(row[headerMap["fy"]], 10, 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing fy: %v\n", r+1, err)
-							continue
-						}
-						if v < 100 {
-							v += 2000
-						}
-						record.FiscalYear = int(v)
-					}
-					{
-						v, err := time.Parse("01/02/06", row[headerMap["rpt_asof_date"]])
-						if err != nil {
-							fmt.Printf("Row %d: error parsing rpt_asof_date: %v\n", r+1, err)
-							continue
-						}
-						record.AsOfDate = v
-					}
-					{
-						v, err := time.Parse("01/02/06", row[headerMap["end_date"]])
-						if err != nil {
-							fmt.Printf("Row %d: error parsing end_date: %v\n", r+1, err)
-							continue
-						}
-						record.EndDate = v
-					}
-					if row[headerMap["available_funds"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["available_funds"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing available_funds: %v\n", r+1, err)
-							continue
-						}
-						record.AvailableAmount = v
-					}
-					if row[headerMap["encumbrances"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["encumbrances"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing encumbrances: %v\n", r+1, err)
-							continue
-						}
-						record.EncumberedAmount = v
-					}
-					if row[headerMap["curr_yr_expen"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["curr_yr_expen"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing curr_yr_expen: %v\n", r+1, err)
-							continue
-						}
-						record.CurrentYearExpenses = v
-					}
-					if row[headerMap["prior_yr_expen"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["prior_yr_expen"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing prior_yr_expen: %v\n", r+1, err)
-							continue
-						}
-						record.PriorYearExpenses = v
-					}
-					if row[headerMap["remain_spend_auth"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["remain_spend_auth"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing remain_spend_auth: %v\n", r+1, err)
-							continue
-						}
-						record.RemainingAmount = v
-					}
-
-					records = append(records, record)
-				}
-
-				err := db.CreateInBatches(records, 100).Error
+			if prepare != nil {
+				prepare(&record)
+			}
+			batch = append(batch, record)
+			if len(batch) == insertBatchSize {
+				err = insert(batch)
 				if err != nil {
-					panic(err)
+					return err
 				}
+				count += len(batch)
+				batch = batch[:0]
+				fmt.Printf("Progress: %d rows (%s)\n", count, progress)
 			}
 		}
+		if len(batch) > 0 {
+			err = insert(batch)
+			if err != nil {
+				return err
+			}
+			count += len(batch)
+		}
+		return nil
+	}, setImportID)
+	l.rejects.addRejects(filename, nil, reader.Rejects)
+	for _, reject := range reader.Rejects {
+		fmt.Printf("Line %d: error parsing %s: %s\n", reject.Line, reject.Column, reject.Reason)
+	}
+	if err != nil {
+		return err
 	}
+	fmt.Printf("Rows: %d (%d duplicates, %d rejected)\n", count, reader.Duplicates, len(reader.Rejects))
+	l.rejects.addSummary(filename, count, len(reader.Rejects), reader.Duplicates)
+	return nil
+}
 
-	files, err = filepath.Glob(baseDirectory + string(filepath.Separator) + "mobius.DGL114.*.csv")
+// loadPrintReport reads a Mobius print report and replaces its import in the database.
+//
+// The report is small enough to read all at once, but the records are still inserted in batches.
+func loadPrintReport[T any](l *loader, file reportFile, parse func(io.Reader) (*mobiusreport.Result[T], error), prepare func(*T), setImportID func(*T, uint)) error {
+	filename := file.Filename
+	fileHandle, err := os.Open(filename)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	for _, filename := range files {
-		division := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(filename), "mobius.DGL114."), ".csv")
-		fmt.Printf("filename: %s\n", filename)
+	defer fileHandle.Close()
 
-		if _, err := os.Stat(filename); err != nil && os.IsNotExist(err) {
-			fmt.Printf("File not found: %s\n", filename)
-			panic(err)
-		} else {
-			fileHandle, err := os.Open(filename)
-			if err != nil {
-				panic(err)
-			}
-			csvReader := csv.NewReader(fileHandle)
-			rows, err := csvReader.ReadAll()
-			if err != nil {
-				panic(err)
-			}
+	result, err := parse(fileHandle)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", filename, err)
+	}
+	l.rejects.addRejects(filename, nil, result.Rejects)
+	for _, reject := range result.Rejects {
+		fmt.Printf("Line %d: error parsing %s: %s\n", reject.Line, reject.Column, reject.Reason)
+	}
+	if len(result.Records) == 0 && len(result.Rejects) == 0 {
+		fmt.Printf("No rows found in the report.\n")
+		return nil
+	}
 
-			if len(rows) == 0 {
-				fmt.Printf("No rows found in the CSV file.\n")
-			} else {
-				fmt.Printf("Rows: %d\n", len(rows))
+	err = replaceImport(l.db, file, l.targetYear, l.targetMonth, insertAll(result.Records, prepare), setImportID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rows: %d (%d subtotals skipped, %d rejected)\n", len(result.Records), result.Subtotals, len(result.Rejects))
+	l.rejects.addSummary(filename, len(result.Records), len(result.Rejects), 0)
+	return nil
+}
 
-				header := rows[0]
-				rows = rows[1:]
-				rows = deduplicate(rows)
+// errUnusable means that a file cannot be loaded at all, such as a CSV that is missing some of its columns.
+//
+// Unless the run is strict, a file like that is skipped (or its fallback is loaded instead).
+var errUnusable = errors.New("file cannot be loaded")
+
+// loadPDFReport reads an FSF PDF report and replaces its import in the database.
+//
+// The rows are only loaded if they add up to the grand total that is printed on the report.
+func loadPDFReport[T any](l *loader, file reportFile, parse func(io.ReaderAt, int64) (*fsfreport.Result[T], error), prepare func(*T), setImportID func(*T, uint)) error {
+	filename := file.Filename
+	fileHandle, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
 
-				headerMap := map[string]int{}
-				for i, column := range header {
-					column = strings.ToLower(column)
-					headerMap[column] = i
-				}
+	fileInfo, err := fileHandle.Stat()
+	if err != nil {
+		return err
+	}
+	result, err := parse(fileHandle, fileInfo.Size())
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", filename, err)
+	}
+	l.rejects.addRejects(filename, nil, result.Rejects)
+	for _, reject := range result.Rejects {
+		fmt.Printf("Line %d: error parsing %s: %s\n", reject.Line, reject.Column, reject.Reason)
+	}
+	fmt.Printf("The rows match the grand total.\n")
 
-				var records []databasemodel.MobiusDGL114
-				for r, row := range rows {
-					row = processFormulas(row)
-
-					record := databasemodel.MobiusDGL114{
-						Division:                  division,
-						DepartmentID:              row[headerMap["deptid"]],
-						DepartmentDescription:     row[headerMap["deptdesc"]],
-						Fund:                      row[headerMap["fund"]],
-						Appropriation:             row[headerMap["apprcode"]],
-						AppropriationType:         row[headerMap["apprtype"]],
-						RevenueAccount:            row[headerMap["revaccount"]],
-						RevenueAccountDescription: row[headerMap["revdescr"]],
-					}
-					{
-						v, err := strconv.ParseInt(row[headerMap["budref"]], 10, 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing budref: %v\n", r+1, err)
-							continue
-						}
-						if v < 100 {
-							v += 2000
-						}
-						record.BudgetYear = int(v)
-					}
-					{
-						v, err := time.Parse("01/02/2006", row[headerMap["rptasofdate"]])
-						if err != nil {
-							fmt.Printf("Row %d: error parsing rptasofdate: %v\n", r+1, err)
-							continue
-						}
-						record.AsOfDate = v
-					}
-					if row[headerMap["gf_current"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["gf_current"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing gf_current: %v\n", r+1, err)
-							continue
-						}
-						record.LocalFundsCurrent = v
-					}
-					if row[headerMap["gf_ytd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["gf_ytd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing gf_ytd: %v\n", r+1, err)
-							continue
-						}
-						record.LocalFundsYearToDate = v
-					}
-					if row[headerMap["sf_current"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["sf_current"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing sf_current: %v\n", r+1, err)
-							continue
-						}
-						record.StateFundsCurrent = v
-					}
-					if row[headerMap["sf_ytd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["sf_ytd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing sf_ytd: %v\n", r+1, err)
-							continue
-						}
-						record.StateFundsYearToDate = v
-					}
-
-					records = append(records, record)
-				}
+	err = replaceImport(l.db, file, l.targetYear, l.targetMonth, insertAll(result.Records, prepare), setImportID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rows: %d (%d subtotals skipped, %d rejected)\n", len(result.Records), result.Subtotals, len(result.Rejects))
+	l.rejects.addSummary(filename, len(result.Records), len(result.Rejects), 0)
+	return nil
+}
 
-				err := db.CreateInBatches(records, 100).Error
-				if err != nil {
-					panic(err)
+// insertAll returns a load function for replaceImport that inserts records that are already in memory, one batch at a time.
+func insertAll[T any](records []T, prepare func(*T)) func(insert func([]T) error) error {
+	return func(insert func([]T) error) error {
+		for start := 0; start < len(records); start += insertBatchSize {
+			batch := records[start:min(start+insertBatchSize, len(records))]
+			if prepare != nil {
+				for i := range batch {
+					prepare(&batch[i])
 				}
 			}
+			err := insert(batch)
+			if err != nil {
+				return err
+			}
 		}
+		return nil
 	}
+}
 
-	files, err = filepath.Glob(baseDirectory + string(filepath.Separator) + "mobius.DGL115.*.csv")
-	if err != nil {
-		panic(err)
-	}
-	for _, filename := range files {
-		division := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(filename), "mobius.DGL115."), ".csv")
-		fmt.Printf("filename: %s\n", filename)
+// replaceImport loads the records for a single report file in one transaction.
+//
+// Any previous import of the same report for the same division and period is deleted first, so that
+// running this command more than once does not double-count anything.  That is the case no matter what the file
+// was called, and includes an import of the same report from one of its other formats, such as the PDF that
+// stands in for a broken CSV.
+//
+// The load function is given a function to insert each batch of records with.
+func replaceImport[T any](db *gorm.DB, file reportFile, year int, month int, load func(insert func([]T) error) error, setImportID func(*T, uint)) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var previousImports []databasemodel.Import
+		err := tx.
+			Where("report_type = ? AND period_year = ? AND period_month = ?", file.ReportType, year, month).
+			// Imports from before the division was recorded can only be matched by their file name.
+			Where("division = ? OR (division IS NULL AND source_file IN ?)", file.division(), sourceFiles(file.Filename)).
+			Find(&previousImports).
+			Error
+		if err != nil {
+			return fmt.Errorf("could not find previous imports: %w", err)
+		}
+		for _, previousImport := range previousImports {
+			fmt.Printf("Replacing import %d (%d records from %s).\n", previousImport.ID, previousImport.RecordCount, previousImport.ImportedAt.Format(time.RFC3339))
 
-		if _, err := os.Stat(filename); err != nil && os.IsNotExist(err) {
-			fmt.Printf("File not found: %s\n", filename)
-			panic(err)
-		} else {
-			fileHandle, err := os.Open(filename)
+			err := tx.Where("import_id = ?", previousImport.ID).Delete(new(T)).Error
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("could not delete records from import %d: %w", previousImport.ID, err)
 			}
-			csvReader := csv.NewReader(fileHandle)
-			rows, err := csvReader.ReadAll()
+			err = tx.Delete(&previousImport).Error
 			if err != nil {
-				panic(err)
+				return fmt.Errorf("could not delete import %d: %w", previousImport.ID, err)
 			}
+		}
 
-			if len(rows) == 0 {
-				fmt.Printf("No rows found in the CSV file.\n")
-			} else {
-				fmt.Printf("Rows: %d\n", len(rows))
-
-				header := rows[0]
-				rows = rows[1:]
-				rows = deduplicate(rows)
-
-				headerMap := map[string]int{}
-				for i, column := range header {
-					column = strings.ToLower(column)
-					headerMap[column] = i
-				}
+		// Rows that were loaded before imports were tracked have no import of their own, so they are replaced
+		// along with the previous imports for their period.
+		query, args := legacyRows(new(T), year, month)
+		result := tx.Where("COALESCE(import_id, 0) = 0").Where(query, args...).Delete(new(T))
+		if result.Error != nil {
+			return fmt.Errorf("could not delete records from before imports were tracked: %w", result.Error)
+		}
+		if result.RowsAffected > 0 {
+			fmt.Printf("Replacing %d records from before imports were tracked.\n", result.RowsAffected)
+		}
 
-				var records []databasemodel.MobiusDGL115
-				for r, row := range rows {
-					row = processFormulas(row)
-
-					record := databasemodel.MobiusDGL115{
-						Division:              division,
-						DepartmentID:          row[headerMap["deptid"]],
-						DepartmentDescription: row[headerMap["dept_descr"]],
-						Account:               row[headerMap["account"]],
-						AccountDescription:    row[headerMap["acct_descr"]],
-					}
-					{
-						v, err := strconv.ParseInt(row[headerMap["fy"]], 10, 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing fy: %v\n", r+1, err)
-							continue
-						}
-						if v < 100 {
-							v += 2000
-						}
-						record.FiscalYear = int(v)
-					}
-					{
-						v, err := strconv.ParseInt(row[headerMap["acct_period"]], 10, 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing acct_period: %v\n", r+1, err)
-							continue
-						}
-						record.AccountPeriod = int(v)
-					}
-					if row[headerMap["gf_mtd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["gf_mtd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing gf_mtd: %v\n", r+1, err)
-							continue
-						}
-						record.LocalFundsMonthToDate = v
-					}
-					if row[headerMap["sf_mtd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["sf_mtd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing sf_mtd: %v\n", r+1, err)
-							continue
-						}
-						record.StateFundsMonthToDate = v
-					}
-					if row[headerMap["totl_mtd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["totl_mtd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing totl_mtd: %v\n", r+1, err)
-							continue
-						}
-						record.TotalFundsMonthToDate = v
-					}
-					if row[headerMap["gf_ytd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["gf_ytd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing gf_ytd: %v\n", r+1, err)
-							continue
-						}
-						record.LocalFundsYearToDate = v
-					}
-					if row[headerMap["sf_ytd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["sf_ytd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing sf_ytd: %v\n", r+1, err)
-							continue
-						}
-						record.StateFundsYearToDate = v
-					}
-					if row[headerMap["totl_ytd"]] != "" {
-						v, err := strconv.ParseFloat(strings.ReplaceAll(row[headerMap["totl_ytd"]], ",", ""), 64)
-						if err != nil {
-							fmt.Printf("Row %d: error parsing totl_ytd: %v\n", r+1, err)
-							continue
-						}
-						record.TotalFundsYearToDate = v
-					}
-
-					records = append(records, record)
-				}
+		importRecord := databasemodel.Import{
+			ReportType: file.ReportType,
+			Division:   file.division(),
+			SourceFile: filepath.Base(file.Filename),
+			Year:       year,
+			Month:      month,
+			ImportedAt: time.Now(),
+		}
+		err = tx.Create(&importRecord).Error
+		if err != nil {
+			return fmt.Errorf("could not create import: %w", err)
+		}
 
-				err := db.CreateInBatches(records, 100).Error
-				if err != nil {
-					panic(err)
-				}
+		// Logging every inserted row would drown out everything else.
+		quietTX := tx.Session(&gorm.Session{Logger: tx.Logger.LogMode(logger.Warn)})
+		err = load(func(records []T) error {
+			for i := range records {
+				setImportID(&records[i], importRecord.ID)
+			}
+			err := quietTX.CreateInBatches(records, 100).Error
+			if err != nil {
+				return fmt.Errorf("could not create records: %w", err)
 			}
+			importRecord.RecordCount += len(records)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&importRecord).Update("record_count", importRecord.RecordCount).Error
+		if err != nil {
+			return fmt.Errorf("could not update import: %w", err)
 		}
+		return nil
+	})
+}
+
+// legacyRows returns the condition for the rows of the given table that are for the given period.
+//
+// This is only used for rows from before imports were tracked, which is why it has to work out the period from the
+// rows themselves.  The FSF summaries from back then do not have a period at all, so any import replaces them.
+func legacyRows(model any, year int, month int) (string, []any) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	fiscalYear, period := fiscalPeriod(year, month)
+
+	switch model.(type) {
+	case *databasemodel.FSFOperatingUnitExpenditureSummary, *databasemodel.FSFOperatingUnitProgramSummary:
+		return "(period_year = ? AND period_month = ?) OR COALESCE(period_year, 0) = 0", []any{year, month}
+	case *databasemodel.FSFDetailedActivity:
+		// The detailed activity report is downloaded one month at a time.
+		return "transaction_date >= ? AND transaction_date < ?", []any{start, end}
+	case *databasemodel.MobiusDGL060, *databasemodel.MobiusDGL114:
+		// These are the "as of" dates that asOfPeriod puts in this period.
+		return "as_of_date >= ? AND as_of_date < ?", []any{start.AddDate(0, 0, asOfGraceDays), end.AddDate(0, 0, asOfGraceDays)}
+	case *databasemodel.MobiusDGL115:
+		// Older loads kept the fiscal year as it was written in the report, which could be just the last two digits.
+		return "fiscal_year IN ? AND account_period = ?", []any{[]int{fiscalYear, fiscalYear % 100}, period}
 	}
+	panic(fmt.Sprintf("no legacy rows for %T", model))
 }
 
-func deduplicate(rows [][]string) [][]string {
-	seen := map[string]bool{}
-	var output [][]string
-	for _, row := range rows {
-		key := strings.Join(row, ",")
-		if _, ok := seen[key]; !ok {
-			seen[key] = true
-			output = append(output, row)
-		}
+// sourceFiles returns the names that the given report file could have been imported under, one for each format.
+//
+// This is only needed for imports from before the division was recorded.
+func sourceFiles(filename string) []string {
+	base := filepath.Base(filename)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	return []string{stem + ".csv", stem + ".pdf", stem + ".txt"}
+}
+
+// progressReader keeps track of how much of a file has been read.
+type progressReader struct {
+	reader io.Reader
+	read   int64
+	total  int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read += int64(n)
+	return n, err
+}
+
+// String returns how much of the file has been read so far.
+func (p *progressReader) String() string {
+	if p.total <= 0 {
+		return fmt.Sprintf("%d bytes", p.read)
 	}
-	return output
+	return fmt.Sprintf("%d%% of %d bytes", p.read*100/p.total, p.total)
 }
 
-func processFormulas(row []string) []string {
-	for c, column := range row {
-		column = strings.TrimSpace(column)
-		if strings.HasPrefix(column, "=") {
-			column = strings.TrimPrefix(column, "=")
-			if strings.HasPrefix(column, `"`) && strings.HasSuffix(column, `"`) {
-				column = strings.TrimPrefix(column, `"`)
-				column = strings.TrimSuffix(column, `"`)
-			}
-			column = strings.TrimSpace(column)
+// fsfPeriod returns the calendar year and month for an FSF summary row.
+//
+// Some exports give the fiscal year along with either the accounting period (a number, such as "3") or the month (a
+// name, such as "September"); if they do, then that wins over the target period.
+func fsfPeriod(fiscalYear int, fiscalMonth string, targetYear int, targetMonth int) (int, int) {
+	fiscalMonth = strings.TrimSpace(fiscalMonth)
+	if fiscalYear == 0 || fiscalMonth == "" {
+		return targetYear, targetMonth
+	}
+	if period, err := strconv.Atoi(fiscalMonth); err == nil {
+		year, month := accountingPeriodMonth(fiscalYear, period)
+		if year == 0 {
+			return targetYear, targetMonth
 		}
-		if strings.HasPrefix(column, `(`) && strings.HasSuffix(column, `)`) {
-			column = strings.TrimPrefix(column, `(`)
-			column = strings.TrimSuffix(column, `)`)
-			column = "-" + column
+		return year, month
+	}
+	for month := time.January; month <= time.December; month++ {
+		if strings.EqualFold(fiscalMonth, month.String()) || strings.EqualFold(fiscalMonth, month.String()[:3]) {
+			year := fiscalYear
+			if int(month) >= fiscalYearStartMonth && fiscalYearStartMonth > 1 {
+				year--
+			}
+			return year, int(month)
 		}
-		row[c] = column
 	}
-	return row
+	return targetYear, targetMonth
+}
+
+// periodEndDate returns the last day of the given month.
+func periodEndDate(year int, month int) time.Time {
+	return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC)
 }
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDatabase opens an empty database file with all of the tables.
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Could not open the database: %v", err)
+	}
+	err = databasemodel.Apply(db)
+	if err != nil {
+		t.Fatalf("Could not apply the model: %v", err)
+	}
+	return db
+}
+
+func TestReplaceImportLegacyRows(t *testing.T) {
+	db := openTestDatabase(t)
+
+	// These were loaded before imports were tracked.
+	legacy := []databasemodel.MobiusDGL060{
+		{Division: "33", AsOfDate: time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), CurrentYearExpenses: 100},
+		{Division: "33", AsOfDate: time.Date(2024, 8, 31, 0, 0, 0, 0, time.UTC), CurrentYearExpenses: 200},
+		// This is from the first date file after September, so it is for September too.
+		{Division: "33", AsOfDate: time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), CurrentYearExpenses: 400},
+		// This is for October.
+		{Division: "33", AsOfDate: time.Date(2024, 10, 31, 0, 0, 0, 0, time.UTC), CurrentYearExpenses: 500},
+	}
+	err := db.Create(&legacy).Error
+	if err != nil {
+		t.Fatalf("Could not create the legacy rows: %v", err)
+	}
+
+	load := func(insert func([]databasemodel.MobiusDGL060) error) error {
+		return insert([]databasemodel.MobiusDGL060{
+			{Division: "33", AsOfDate: time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC), CurrentYearExpenses: 300},
+		})
+	}
+	setImportID := func(record *databasemodel.MobiusDGL060, importID uint) {
+		record.ImportID = importID
+	}
+	// Loading the same period twice must leave only one copy.
+	for i := 0; i < 2; i++ {
+		err = replaceImport(db, reportFile{Filename: "mobius.DGL060.95.txt", ReportType: reportMobiusDGL060, Division: "95"}, 2024, 9, load, setImportID)
+		if err != nil {
+			t.Fatalf("Could not replace the import: %v", err)
+		}
+	}
+
+	var amounts []databasemodel.Money
+	err = db.Model(&databasemodel.MobiusDGL060{}).Order("as_of_date").Pluck("current_year_expenses", &amounts).Error
+	if err != nil {
+		t.Fatalf("Could not read the rows: %v", err)
+	}
+	expected := []databasemodel.Money{200, 300, 500}
+	if len(amounts) != len(expected) {
+		t.Fatalf("Expected %v; got %v.", expected, amounts)
+	}
+	for i := range expected {
+		if amounts[i] != expected[i] {
+			t.Errorf("Expected %v; got %v.", expected, amounts)
+			break
+		}
+	}
+}
+
+func TestLegacyRows(t *testing.T) {
+	db := openTestDatabase(t)
+
+	rows := []databasemodel.MobiusDGL115{
+		{Division: "33", FiscalYear: 2025, AccountPeriod: 3},
+		{Division: "33", FiscalYear: 25, AccountPeriod: 3},
+		{Division: "33", FiscalYear: 2025, AccountPeriod: 2},
+		{Division: "33", FiscalYear: 2024, AccountPeriod: 3},
+	}
+	err := db.Create(&rows).Error
+	if err != nil {
+		t.Fatalf("Could not create the rows: %v", err)
+	}
+
+	query, args := legacyRows(new(databasemodel.MobiusDGL115), 2024, 9)
+	var count int64
+	err = db.Model(&databasemodel.MobiusDGL115{}).Where(query, args...).Count(&count).Error
+	if err != nil {
+		t.Fatalf("Could not count the rows: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 rows for September 2024; got %d.", count)
+	}
+}
+
+func TestReplaceImportByDivision(t *testing.T) {
+	db := openTestDatabase(t)
+
+	setImportID := func(record *databasemodel.MobiusDGL115, importID uint) {
+		record.ImportID = importID
+	}
+	loads := []struct {
+		file     reportFile
+		division string
+	}{
+		{reportFile{Filename: "a/report.txt", ReportType: reportMobiusDGL115, detectedDivision: "33"}, "33"},
+		{reportFile{Filename: "b/report.txt", ReportType: reportMobiusDGL115, detectedDivision: "51"}, "51"},
+		// This is the same report for the first division again, under a different name.
+		{reportFile{Filename: "a/report (1).txt", ReportType: reportMobiusDGL115, detectedDivision: "33"}, "33"},
+	}
+	for _, load := range loads {
+		err := replaceImport(db, load.file, 2024, 9, insertAll([]databasemodel.MobiusDGL115{{Division: load.division}}, nil), setImportID)
+		if err != nil {
+			t.Fatalf("Could not replace the import of %s: %v", load.file.Filename, err)
+		}
+	}
+
+	var divisions []string
+	err := db.Model(&databasemodel.MobiusDGL115{}).Order("division").Pluck("division", &divisions).Error
+	if err != nil {
+		t.Fatalf("Could not read the rows: %v", err)
+	}
+	if len(divisions) != 2 || divisions[0] != "33" || divisions[1] != "51" {
+		t.Errorf("Expected one row for each division; got %v.", divisions)
+	}
+}
+
+func TestFSFPeriod(t *testing.T) {
+	rows := []struct {
+		fiscalYear  int
+		fiscalMonth string
+		year        int
+		month       int
+	}{
+		// The file does not say, so the target period is used.
+		{fiscalYear: 0, fiscalMonth: "", year: 2024, month: 10},
+		{fiscalYear: 2025, fiscalMonth: "", year: 2024, month: 10},
+		// Accounting periods start in July.
+		{fiscalYear: 2025, fiscalMonth: "1", year: 2024, month: 7},
+		{fiscalYear: 2025, fiscalMonth: "3", year: 2024, month: 9},
+		{fiscalYear: 2025, fiscalMonth: "6", year: 2024, month: 12},
+		{fiscalYear: 2025, fiscalMonth: "7", year: 2025, month: 1},
+		{fiscalYear: 2025, fiscalMonth: "12", year: 2025, month: 6},
+		{fiscalYear: 2025, fiscalMonth: "13", year: 2024, month: 10},
+		// Month names are calendar months within the fiscal year.
+		{fiscalYear: 2025, fiscalMonth: "September", year: 2024, month: 9},
+		{fiscalYear: 2025, fiscalMonth: "jan", year: 2025, month: 1},
+		{fiscalYear: 2025, fiscalMonth: "Smarch", year: 2024, month: 10},
+	}
+	for _, row := range rows {
+		year, month := fsfPeriod(row.fiscalYear, row.fiscalMonth, 2024, 10)
+		if year != row.year || month != row.month {
+			t.Errorf("%d %q: expected %d-%02d; got %d-%02d.", row.fiscalYear, row.fiscalMonth, row.year, row.month, year, month)
+		}
+	}
+}
+
+func TestLoadFileMissingColumns(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), reportFSFExpenditureSummary+".csv")
+	err := os.WriteFile(filename, []byte("district,div,operatingunit\nChristina,33,1000\n"), 0644)
+	if err != nil {
+		t.Fatalf("Could not write the file: %v", err)
+	}
+	file := reportFile{Filename: filename, ReportType: reportFSFExpenditureSummary, Format: formatCSV}
+	// This is the same report as a PDF, which the "report" command always downloads along with the CSV.
+	withFallback := file
+	withFallback.Fallback = &reportFile{Filename: "../../fsfreport/testdata/fsf.operating-unit-expenditure-summary.pdf", ReportType: reportFSFExpenditureSummary, Format: formatPDF}
+
+	rows := []struct {
+		name   string
+		file   reportFile
+		strict bool
+		fails  bool
+		loaded bool
+	}{
+		{name: "skipped", file: file, strict: false, fails: false, loaded: false},
+		{name: "strict", file: file, strict: true, fails: true, loaded: false},
+		{name: "fallback", file: withFallback, strict: false, fails: false, loaded: true},
+		{name: "strict with fallback", file: withFallback, strict: true, fails: true, loaded: false},
+	}
+	for _, row := range rows {
+		t.Run(row.name, func(t *testing.T) {
+			l := &loader{db: openTestDatabase(t), targetYear: 2024, targetMonth: 9, strict: row.strict, rejects: &rejectLog{}}
+			err := loadFile(l, row.file)
+			if row.fails && err == nil {
+				t.Errorf("Expected an error.")
+			}
+			if !row.fails && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			var missing int
+			for _, entry := range l.rejects.entries {
+				if entry.Reason == "missing column" {
+					missing++
+				}
+			}
+			if missing == 0 {
+				t.Errorf("Expected the missing columns to be rejects.")
+			}
+			var count int64
+			l.db.Model(&databasemodel.FSFOperatingUnitExpenditureSummary{}).Count(&count)
+			if row.loaded && count == 0 {
+				t.Errorf("Expected the fallback to be loaded.")
+			}
+			if !row.loaded && count != 0 {
+				t.Errorf("Expected nothing to be loaded; got %d rows.", count)
+			}
+		})
+	}
+}
+
+func TestLoadFileMissingColumnsEveryCSV(t *testing.T) {
+	// Every CSV is skipped the same way, rather than loading its missing columns as zeros.
+	for _, reportType := range []string{reportFSFExpenditureSummary, reportFSFProgramSummary, reportFSFDetailedActivity, reportMobiusDGL060, reportMobiusDGL114, reportMobiusDGL115} {
+		t.Run(reportType, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), reportType+".csv")
+			err := os.WriteFile(filename, []byte("fund,account\n100,5000\n"), 0644)
+			if err != nil {
+				t.Fatalf("Could not write the file: %v", err)
+			}
+			l := &loader{db: openTestDatabase(t), targetYear: 2024, targetMonth: 9, rejects: &rejectLog{}}
+			err = loadFile(l, reportFile{Filename: filename, ReportType: reportType, Format: formatCSV})
+			if err != nil {
+				t.Fatalf("Expected the file to be skipped; got %v", err)
+			}
+			if len(l.rejects.entries) == 0 {
+				t.Errorf("Expected the missing columns to be rejects.")
+			}
+			var count int64
+			l.db.Model(&databasemodel.Import{}).Count(&count)
+			if count != 0 {
+				t.Errorf("Expected nothing to be imported; got %d imports.", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/tekkamanendless/cboc-tools/csvloader"
+)
+
+// rejectLog collects everything that could not be loaded from every file, along with how many rows were accepted.
+type rejectLog struct {
+	entries   []rejectEntry
+	summaries []fileSummary
+}
+
+// rejectEntry is a single line of the rejects file.
+type rejectEntry struct {
+	File   string
+	Row    int // This is the line of the file; the header is line 1.
+	Column string
+	Value  string
+	Reason string
+}
+
+// fileSummary is how one file went.
+type fileSummary struct {
+	File       string
+	Accepted   int
+	Rejected   int
+	Duplicates int
+}
+
+// addRejects records the missing columns and the rejected rows from a file.
+func (l *rejectLog) addRejects(filename string, missingColumns []string, rejects []csvloader.Reject) {
+	for _, column := range missingColumns {
+		l.entries = append(l.entries, rejectEntry{
+			File:   filepath.Base(filename),
+			Row:    1,
+			Column: column,
+			Reason: "missing column",
+		})
+	}
+	for _, reject := range rejects {
+		l.entries = append(l.entries, rejectEntry{
+			File:   filepath.Base(filename),
+			Row:    reject.Line,
+			Column: reject.Column,
+			Value:  reject.Value,
+			Reason: reject.Reason,
+		})
+	}
+}
+
+// addSummary records how many rows from a file were loaded into the database.
+func (l *rejectLog) addSummary(filename string, accepted int, rejected int, duplicates int) {
+	l.summaries = append(l.summaries, fileSummary{
+		File:       filepath.Base(filename),
+		Accepted:   accepted,
+		Rejected:   rejected,
+		Duplicates: duplicates,
+	})
+}
+
+// write saves the rejects as a CSV file.
+//
+// If there are no rejects, then there is no file; one from an earlier run is removed, so that it is never mistaken
+// for the latest run.
+func (l *rejectLog) write(filename string) error {
+	if len(l.entries) == 0 {
+		err := os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	fileHandle, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
+
+	w := csv.NewWriter(fileHandle)
+	err = w.Write([]string{"file", "row", "column", "value", "reason"})
+	if err != nil {
+		return err
+	}
+	for _, entry := range l.entries {
+		err = w.Write([]string{entry.File, strconv.Itoa(entry.Row), entry.Column, entry.Value, entry.Reason})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	err = w.Error()
+	if err != nil {
+		return err
+	}
+	return fileHandle.Close()
+}
+
+// printSummary prints the accepted and rejected counts for each file, and then for the whole run.
+func (l *rejectLog) printSummary() {
+	var total fileSummary
+	fmt.Printf("Summary:\n")
+	for _, summary := range l.summaries {
+		fmt.Printf("   %s: %d accepted, %d rejected, %d duplicates\n", summary.File, summary.Accepted, summary.Rejected, summary.Duplicates)
+		total.Accepted += summary.Accepted
+		total.Rejected += summary.Rejected
+		total.Duplicates += summary.Duplicates
+	}
+	fmt.Printf("Total: %d accepted, %d rejected, %d duplicates\n", total.Accepted, total.Rejected, total.Duplicates)
+}
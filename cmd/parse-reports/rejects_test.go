@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tekkamanendless/cboc-tools/csvloader"
+)
+
+func TestRejectLogWrite(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "rejects.csv")
+
+	l := &rejectLog{}
+	l.addRejects("/some/where/report.csv", []string{"budgetamt"}, []csvloader.Reject{{Line: 3, Column: "expendedamt", Value: "abc", Reason: "invalid amount"}})
+	err := l.write(filename)
+	if err != nil {
+		t.Fatalf("Could not write the rejects: %v", err)
+	}
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Could not read the rejects: %v", err)
+	}
+	expected := "file,row,column,value,reason\nreport.csv,1,budgetamt,,missing column\nreport.csv,3,expendedamt,abc,invalid amount\n"
+	if string(contents) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, contents)
+	}
+
+	// A run with no rejects has no file, and does not leave the one from the last run behind.
+	l = &rejectLog{}
+	err = l.write(filename)
+	if err != nil {
+		t.Fatalf("Could not write the rejects: %v", err)
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("Expected no rejects file; got %v", err)
+	}
+	// There is nothing to remove the second time around.
+	err = l.write(filename)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"github.com/tekkamanendless/cboc-tools/fsfreport"
+	"github.com/tekkamanendless/cboc-tools/mobiusreport"
+)
+
+// These are the kinds of reports that can be loaded.
+const (
+	reportFSFExpenditureSummary = "fsf.operating-unit-expenditure-summary"
+	reportFSFProgramSummary     = "fsf.operating-unit-program-summary"
+	reportFSFDetailedActivity   = "fsf.detailed-activity-report"
+	reportMobiusDGL060          = "mobius.DGL060"
+	reportMobiusDGL114          = "mobius.DGL114"
+	reportMobiusDGL115          = "mobius.DGL115"
+)
+
+// These are the formats that a report may come in.
+const (
+	formatCSV   = "csv"
+	formatPDF   = "pdf"
+	formatPrint = "txt" // This is the Mobius fixed-width print report.
+)
+
+// reportFile is a file to load and what kind of report it is.
+type reportFile struct {
+	Filename   string
+	ReportType string
+	Format     string
+	Division   string      // This is the division from the filename of a Mobius report; if it is empty, then it comes from the department of each row.
+	Year       int         // This is the calendar year that the contents are for, if they say.
+	Month      int         // This is the calendar month that the contents are for, if they say.
+	Fallback   *reportFile // This is the same report in another format, to load if this one cannot be.
+
+	detectedDivision string // This is the division from the contents of a Mobius report.
+}
+
+// division returns the division that the whole report is for, if there is one.
+func (f reportFile) division() string {
+	if f.Division != "" {
+		return f.Division
+	}
+	return f.detectedDivision
+}
+
+// key identifies a report, regardless of its format, so that the same report is only loaded once.
+func (f reportFile) key() string {
+	return f.ReportType + "/" + f.division()
+}
+
+// namedFiles finds the reports in the base directory by the names that the "report" command saves them under.
+//
+// The FSF summaries must be there (as either the CSV or the PDF); everything else is optional.
+func namedFiles(baseDirectory string) ([]reportFile, error) {
+	var files []reportFile
+	for _, reportType := range []string{reportFSFExpenditureSummary, reportFSFProgramSummary} {
+		file := reportFile{
+			Filename:   filepath.Join(baseDirectory, reportType+".csv"),
+			ReportType: reportType,
+			Format:     formatCSV,
+		}
+		pdfFile := reportFile{
+			Filename:   filepath.Join(baseDirectory, reportType+".pdf"),
+			ReportType: reportType,
+			Format:     formatPDF,
+		}
+		// The PDF has the same data, so it is the fallback for when the CSV is missing or broken.
+		if _, err := os.Stat(pdfFile.Filename); err == nil {
+			file.Fallback = &pdfFile
+		} else if _, err := os.Stat(file.Filename); err != nil {
+			fmt.Printf("File not found: %s\n", file.Filename)
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	// Older download directories may not have the detailed activity report, so this one is optional.
+	filename := filepath.Join(baseDirectory, reportFSFDetailedActivity+".csv")
+	if _, err := os.Stat(filename); err != nil && os.IsNotExist(err) {
+		fmt.Printf("File not found: %s\n", filename)
+	} else {
+		files = append(files, reportFile{Filename: filename, ReportType: reportFSFDetailedActivity, Format: formatCSV})
+	}
+
+	for _, reportType := range []string{reportMobiusDGL060, reportMobiusDGL114, reportMobiusDGL115} {
+		byDivision := map[string]*reportFile{}
+		var divisions []string
+		for _, format := range []string{formatCSV, formatPrint} {
+			matches, err := filepath.Glob(filepath.Join(baseDirectory, reportType+".*."+format))
+			if err != nil {
+				return nil, err
+			}
+			for _, filename := range matches {
+				file := reportFile{
+					Filename:   filename,
+					ReportType: reportType,
+					Format:     format,
+					Division:   namedDivision(filename, reportType),
+				}
+				// The CSV extract is the better source, so the print report is only the fallback.
+				if existing, ok := byDivision[file.Division]; ok {
+					existing.Fallback = &file
+					continue
+				}
+				byDivision[file.Division] = &file
+				divisions = append(divisions, file.Division)
+			}
+		}
+		for _, division := range divisions {
+			files = append(files, *byDivision[division])
+		}
+	}
+	return files, nil
+}
+
+// loadFile loads a report file, or its fallback if it cannot be loaded.
+func loadFile(l *loader, file reportFile) error {
+	fmt.Printf("Loading %s: %s (%s)\n", file.Filename, file.ReportType, file.Format)
+	err := loadFileFormat(l, file)
+	// In a strict run, a file that is missing columns is an error, even if there is something else to load instead.
+	if errors.Is(err, errUnusable) && l.strict {
+		return fmt.Errorf("could not load %s: %w", file.Filename, err)
+	}
+	if err != nil && file.Fallback != nil {
+		fmt.Printf("Could not load %s: %v\n", file.Filename, err)
+		return loadFile(l, *file.Fallback)
+	}
+	if errors.Is(err, errUnusable) {
+		fmt.Printf("Skipping %s: %v\n", file.Filename, err)
+		return nil
+	}
+	return err
+}
+
+// loadFileFormat loads a single report file.
+func loadFileFormat(l *loader, file reportFile) error {
+	switch file.ReportType + "/" + file.Format {
+	case reportFSFExpenditureSummary + "/" + formatCSV:
+		return loadReport(l, file, fsfExpenditureSummaryPrepare(l), fsfExpenditureSummarySetImportID)
+	case reportFSFExpenditureSummary + "/" + formatPDF:
+		return loadPDFReport(l, file, fsfreport.ParseOperatingUnitExpenditureSummary, fsfExpenditureSummaryPrepare(l), fsfExpenditureSummarySetImportID)
+	case reportFSFProgramSummary + "/" + formatCSV:
+		return loadReport(l, file, fsfProgramSummaryPrepare(l), fsfProgramSummarySetImportID)
+	case reportFSFProgramSummary + "/" + formatPDF:
+		return loadPDFReport(l, file, fsfreport.ParseOperatingUnitProgramSummary, fsfProgramSummaryPrepare(l), fsfProgramSummarySetImportID)
+	case reportFSFDetailedActivity + "/" + formatCSV:
+		return loadReport(l, file, nil, func(record *databasemodel.FSFDetailedActivity, importID uint) {
+			record.ImportID = importID
+		})
+	case reportMobiusDGL060 + "/" + formatCSV, reportMobiusDGL060 + "/" + formatPrint:
+		prepare := func(record *databasemodel.MobiusDGL060) {
+			record.Division = recordDivision(file.division(), record.DepartmentID)
+		}
+		setImportID := func(record *databasemodel.MobiusDGL060, importID uint) {
+			record.ImportID = importID
+		}
+		if file.Format == formatPrint {
+			return loadPrintReport(l, file, mobiusreport.ParseDGL060, prepare, setImportID)
+		}
+		return loadReport(l, file, prepare, setImportID)
+	case reportMobiusDGL114 + "/" + formatCSV, reportMobiusDGL114 + "/" + formatPrint:
+		prepare := func(record *databasemodel.MobiusDGL114) {
+			record.Division = recordDivision(file.division(), record.DepartmentID)
+		}
+		setImportID := func(record *databasemodel.MobiusDGL114, importID uint) {
+			record.ImportID = importID
+		}
+		if file.Format == formatPrint {
+			return loadPrintReport(l, file, mobiusreport.ParseDGL114, prepare, setImportID)
+		}
+		return loadReport(l, file, prepare, setImportID)
+	case reportMobiusDGL115 + "/" + formatCSV, reportMobiusDGL115 + "/" + formatPrint:
+		prepare := func(record *databasemodel.MobiusDGL115) {
+			record.Division = recordDivision(file.division(), record.DepartmentID)
+		}
+		setImportID := func(record *databasemodel.MobiusDGL115, importID uint) {
+			record.ImportID = importID
+		}
+		if file.Format == formatPrint {
+			return loadPrintReport(l, file, mobiusreport.ParseDGL115, prepare, setImportID)
+		}
+		return loadReport(l, file, prepare, setImportID)
+	}
+	return fmt.Errorf("%s: unsupported report: %s (%s)", file.Filename, file.ReportType, file.Format)
+}
+
+// fsfExpenditureSummaryPrepare fills in the period of an expenditure summary row.
+func fsfExpenditureSummaryPrepare(l *loader) func(*databasemodel.FSFOperatingUnitExpenditureSummary) {
+	return func(record *databasemodel.FSFOperatingUnitExpenditureSummary) {
+		record.Year, record.Month = fsfPeriod(record.FiscalYear, record.FiscalMonth, l.targetYear, l.targetMonth)
+		record.AsOfDate = periodEndDate(record.Year, record.Month)
+	}
+}
+
+func fsfExpenditureSummarySetImportID(record *databasemodel.FSFOperatingUnitExpenditureSummary, importID uint) {
+	record.ImportID = importID
+}
+
+// fsfProgramSummaryPrepare fills in the period of a program summary row.
+func fsfProgramSummaryPrepare(l *loader) func(*databasemodel.FSFOperatingUnitProgramSummary) {
+	return func(record *databasemodel.FSFOperatingUnitProgramSummary) {
+		record.Year, record.Month = fsfPeriod(record.FiscalYear, record.FiscalMonth, l.targetYear, l.targetMonth)
+		record.AsOfDate = periodEndDate(record.Year, record.Month)
+	}
+}
+
+func fsfProgramSummarySetImportID(record *databasemodel.FSFOperatingUnitProgramSummary, importID uint) {
+	record.ImportID = importID
+}
+
+// These describe how Mobius numbers the departments; the defaults match the built-in profile of the "report" command.
+var (
+	mobiusReportFilePattern = "95%s00"                         // This turns a division into the name of its report file in Mobius; the "%s" is the division.
+	mobiusDivisions         = []string{"33", "51", "56", "60"} // These are the divisions that the Mobius reports may be for.
+)
+
+// recordDivision returns the division for a Mobius row.
+//
+// The division of the whole file wins; otherwise, it comes from the department.
+func recordDivision(fileDivision string, departmentID string) string {
+	if fileDivision != "" {
+		return fileDivision
+	}
+	if division := divisionFromDepartment(departmentID); division != "" {
+		return division
+	}
+	return departmentID
+}
+
+// divisionFromDepartment returns the division for a Mobius department ID, such as "33" for "95330000".
+//
+// The "report" command asks Mobius for the report file of each division, and the IDs of the departments in that report
+// start with the name of the report file.  If the department is not in any of the divisions, then this returns "".
+func divisionFromDepartment(departmentID string) string {
+	for _, division := range mobiusDivisions {
+		if strings.HasPrefix(departmentID, fmt.Sprintf(mobiusReportFilePattern, division)) {
+			return division
+		}
+	}
+	return ""
+}
+
+// namedDivision returns the division from the name that the "report" command saves a Mobius report under, such as "33"
+// for "mobius.DGL060.33.csv".
+//
+// If the file was not named like that, then this returns "".
+func namedDivision(filename string, reportType string) string {
+	base := filepath.Base(filename)
+	rest, ok := strings.CutPrefix(base, reportType+".")
+	if !ok {
+		return ""
+	}
+	division, ok := strings.CutSuffix(rest, filepath.Ext(base))
+	if !ok {
+		return ""
+	}
+	return division
+}
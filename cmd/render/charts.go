@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+)
+
+// chartColors is the palette used for the series in a chart, in order.
+//
+// The first three match the expended/encumbered/available colors of the budget bars.
+var chartColors = []string{
+	"#ff0000",
+	"#ff8800",
+	"#88ff88",
+	"#4488ff",
+	"#88ccff",
+	"#aa66cc",
+	"#ffcc00",
+	"#66cccc",
+	"#cc6699",
+	"#999999",
+}
+
+// maxPieSlices is the most slices that a pie chart will have; everything else goes into "Other".
+const maxPieSlices = 10
+
+// chartSeries is a single labeled value, summed up from the rows.
+type chartSeries struct {
+	Label string
+	Value float64
+}
+
+// sumByLabel adds up the value column for each distinct label, keeping the order in which the labels first appear.
+func sumByLabel(rows []Row, labelColumn string, valueColumn string) []chartSeries {
+	var output []chartSeries
+	for _, group := range groupRows(rows, labelColumn) {
+		output = append(output, chartSeries{
+			Label: group.Key,
+			Value: group.Sum(valueColumn).Float64(),
+		})
+	}
+	return output
+}
+
+// columnLabel turns a column name like "expended_amount" into "Expended Amount" for the legend.
+func columnLabel(column string) string {
+	words := strings.Split(column, "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// chartLegend draws a legend starting at the given position.
+func chartLegend(w *strings.Builder, x float64, y float64, labels []string) {
+	for i, label := range labels {
+		fmt.Fprintf(w, `<rect x="%.1f" y="%.1f" width="10" height="10" fill="%s"/>`, x, y+float64(i)*16, chartColors[i%len(chartColors)])
+		fmt.Fprintf(w, `<text x="%.1f" y="%.1f" font-size="11">%s</text>`, x+14, y+float64(i)*16+9, template.HTMLEscapeString(label))
+	}
+}
+
+// formatChartMoney is a compact money format for axis labels.
+func formatChartMoney(amount float64) string {
+	switch {
+	case math.Abs(amount) >= 1_000_000:
+		return fmt.Sprintf("$%.1fM", amount/1_000_000)
+	case math.Abs(amount) >= 1_000:
+		return fmt.Sprintf("$%.0fK", amount/1_000)
+	default:
+		return fmt.Sprintf("$%.0f", amount)
+	}
+}
+
+// stackedBarChart draws one horizontal bar per label, with one segment per value column.
+func stackedBarChart(rows []Row, labelColumn string, valueColumns ...string) template.HTML {
+	groups := groupRows(rows, labelColumn)
+	if len(groups) == 0 || len(valueColumns) == 0 {
+		return ""
+	}
+
+	const width = 800.0
+	const labelWidth = 160.0
+	const barHeight = 20.0
+	const barGap = 8.0
+	const legendWidth = 140.0
+	barAreaWidth := width - labelWidth - legendWidth - 20
+
+	var maxTotal float64
+	for _, group := range groups {
+		var total float64
+		for _, column := range valueColumns {
+			total += math.Max(0, group.Sum(column).Float64())
+		}
+		maxTotal = math.Max(maxTotal, total)
+	}
+	if maxTotal == 0 {
+		return ""
+	}
+
+	height := math.Max(float64(len(groups))*(barHeight+barGap)+barGap, float64(len(valueColumns))*16+barGap)
+
+	var w strings.Builder
+	fmt.Fprintf(&w, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" width="100%%" viewBox="0 0 %.0f %.0f">`, width, height)
+	for i, group := range groups {
+		y := barGap + float64(i)*(barHeight+barGap)
+		fmt.Fprintf(&w, `<text x="%.1f" y="%.1f" font-size="11" text-anchor="end">%s</text>`, labelWidth-6, y+barHeight*0.7, template.HTMLEscapeString(group.Key))
+
+		x := labelWidth
+		for c, column := range valueColumns {
+			value := math.Max(0, group.Sum(column).Float64())
+			segmentWidth := barAreaWidth * value / maxTotal
+			fmt.Fprintf(&w, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`, x, y, segmentWidth, barHeight, chartColors[c%len(chartColors)], template.HTMLEscapeString(group.Key+" "+columnLabel(column)), formatChartMoney(value))
+			x += segmentWidth
+		}
+	}
+	fmt.Fprintf(&w, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="end" fill="#666666">%s</text>`, labelWidth+barAreaWidth, height-1, formatChartMoney(maxTotal))
+
+	var legendLabels []string
+	for _, column := range valueColumns {
+		legendLabels = append(legendLabels, columnLabel(column))
+	}
+	chartLegend(&w, width-legendWidth, barGap, legendLabels)
+
+	w.WriteString(`</svg>`)
+	return template.HTML(w.String())
+}
+
+// pieChart draws the share of the value column for each label.
+//
+// Only positive values can be shown, and the smallest slices are combined into "Other".
+func pieChart(rows []Row, labelColumn string, valueColumn string) template.HTML {
+	var series []chartSeries
+	for _, s := range sumByLabel(rows, labelColumn, valueColumn) {
+		if s.Value > 0 {
+			series = append(series, s)
+		}
+	}
+	if len(series) == 0 {
+		return ""
+	}
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].Value > series[j].Value
+	})
+	if len(series) > maxPieSlices {
+		other := chartSeries{Label: "Other"}
+		for _, s := range series[maxPieSlices-1:] {
+			other.Value += s.Value
+		}
+		series = append(series[:maxPieSlices-1], other)
+	}
+
+	var total float64
+	for _, s := range series {
+		total += s.Value
+	}
+
+	const width = 500.0
+	const height = 220.0
+	const radius = 100.0
+	const centerX = 110.0
+	const centerY = 110.0
+
+	var w strings.Builder
+	fmt.Fprintf(&w, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" width="100%%" viewBox="0 0 %.0f %.0f">`, width, height)
+	if len(series) == 1 {
+		fmt.Fprintf(&w, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s"><title>%s: %s</title></circle>`, centerX, centerY, radius, chartColors[0], template.HTMLEscapeString(series[0].Label), formatChartMoney(series[0].Value))
+	} else {
+		angle := -math.Pi / 2
+		for i, s := range series {
+			sweep := 2 * math.Pi * s.Value / total
+			startX := centerX + radius*math.Cos(angle)
+			startY := centerY + radius*math.Sin(angle)
+			endX := centerX + radius*math.Cos(angle+sweep)
+			endY := centerY + radius*math.Sin(angle+sweep)
+			largeArc := 0
+			if sweep > math.Pi {
+				largeArc = 1
+			}
+			fmt.Fprintf(&w, `<path d="M %.1f %.1f L %.2f %.2f A %.1f %.1f 0 %d 1 %.2f %.2f Z" fill="%s" stroke="#ffffff" stroke-width="1"><title>%s: %s (%.1f%%)</title></path>`, centerX, centerY, startX, startY, radius, radius, largeArc, endX, endY, chartColors[i%len(chartColors)], template.HTMLEscapeString(s.Label), formatChartMoney(s.Value), 100*s.Value/total)
+			angle += sweep
+		}
+	}
+
+	var legendLabels []string
+	for _, s := range series {
+		legendLabels = append(legendLabels, fmt.Sprintf("%s (%.1f%%)", s.Label, 100*s.Value/total))
+	}
+	chartLegend(&w, centerX+radius+30, 10, legendLabels)
+
+	w.WriteString(`</svg>`)
+	return template.HTML(w.String())
+}
+
+// lineChart draws one line per value column, with the label column along the bottom.
+//
+// A line needs at least two points, so nothing is drawn for fewer than two labels.
+func lineChart(rows []Row, labelColumn string, valueColumns ...string) template.HTML {
+	groups := groupRows(rows, labelColumn)
+	if len(groups) < 2 || len(valueColumns) == 0 {
+		return ""
+	}
+
+	const width = 800.0
+	const height = 260.0
+	const left = 70.0
+	const right = 160.0
+	const top = 10.0
+	const bottom = 30.0
+	plotWidth := width - left - right
+	plotHeight := height - top - bottom
+
+	var minValue, maxValue float64
+	for _, group := range groups {
+		for _, column := range valueColumns {
+			value := group.Sum(column).Float64()
+			minValue = math.Min(minValue, value)
+			maxValue = math.Max(maxValue, value)
+		}
+	}
+	if maxValue == minValue {
+		maxValue = minValue + 1
+	}
+
+	xFor := func(i int) float64 {
+		return left + plotWidth*float64(i)/float64(len(groups)-1)
+	}
+	yFor := func(value float64) float64 {
+		return top + plotHeight*(maxValue-value)/(maxValue-minValue)
+	}
+
+	var w strings.Builder
+	fmt.Fprintf(&w, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" width="100%%" viewBox="0 0 %.0f %.0f">`, width, height)
+
+	// Axes.
+	fmt.Fprintf(&w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999999"/>`, left, top, left, top+plotHeight)
+	fmt.Fprintf(&w, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#999999"/>`, left, yFor(0), left+plotWidth, yFor(0))
+	fmt.Fprintf(&w, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="end">%s</text>`, left-4, top+8, formatChartMoney(maxValue))
+	fmt.Fprintf(&w, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="end">%s</text>`, left-4, top+plotHeight, formatChartMoney(minValue))
+	for i, group := range groups {
+		fmt.Fprintf(&w, `<text x="%.1f" y="%.1f" font-size="10" text-anchor="middle">%s</text>`, xFor(i), height-10, template.HTMLEscapeString(group.Key))
+	}
+
+	for c, column := range valueColumns {
+		color := chartColors[c%len(chartColors)]
+		var points []string
+		for i, group := range groups {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", xFor(i), yFor(group.Sum(column).Float64())))
+		}
+		fmt.Fprintf(&w, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(points, " "), color)
+		for i, group := range groups {
+			fmt.Fprintf(&w, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s %s: %s</title></circle>`, xFor(i), yFor(group.Sum(column).Float64()), color, template.HTMLEscapeString(group.Key), template.HTMLEscapeString(columnLabel(column)), formatChartMoney(group.Sum(column).Float64()))
+		}
+	}
+
+	var legendLabels []string
+	for _, column := range valueColumns {
+		legendLabels = append(legendLabels, columnLabel(column))
+	}
+	chartLegend(&w, width-right+20, top, legendLabels)
+
+	w.WriteString(`</svg>`)
+	return template.HTML(w.String())
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestCharts(t *testing.T) {
+	// Money comes from the queries in cents.
+	budget := []Row{
+		{"division": "33", "expended_amount": int64(30000), "encumbered_amount": int64(10000)},
+		{"division": "51", "expended_amount": int64(10000), "encumbered_amount": int64(-5000)},
+	}
+	periods := []Row{
+		{"period": "2024-07", "available_amount": int64(0)},
+		{"period": "2024-08", "available_amount": int64(100000)},
+	}
+	var programs []Row
+	for i := 0; i < 12; i++ {
+		programs = append(programs, Row{"program_code": fmt.Sprintf("P%02d", i), "expended_amount": int64(10000 * (12 - i))})
+	}
+
+	rows := []struct {
+		name     string
+		chart    template.HTML
+		contains []string // If this is nil, then nothing should be drawn.
+		excludes []string
+	}{
+		{name: "stacked bar: nothing", chart: stackedBarChart(nil, "division", "expended_amount")},
+		{name: "stacked bar: no columns", chart: stackedBarChart(budget, "division")},
+		{name: "stacked bar: all zero", chart: stackedBarChart([]Row{{"division": "33", "expended_amount": int64(0)}}, "division", "expended_amount")},
+		{
+			name:  "stacked bar",
+			chart: stackedBarChart(budget, "division", "expended_amount", "encumbered_amount"),
+			contains: []string{
+				// The longest bar fills the 480 wide bar area, and the rest are scaled to it.
+				`x="160.0" y="8.0" width="360.0"`,
+				`x="520.0" y="8.0" width="120.0"`,
+				`x="160.0" y="36.0" width="120.0"`,
+				// A negative amount cannot be drawn.
+				`x="280.0" y="36.0" width="0.0"`,
+				`>$400<`,
+				"Expended Amount",
+				"Encumbered Amount",
+			},
+		},
+		{name: "pie: nothing", chart: pieChart(nil, "division", "expended_amount")},
+		{name: "pie: nothing positive", chart: pieChart([]Row{{"division": "33", "expended_amount": int64(-100)}}, "division", "expended_amount")},
+		{
+			name:     "pie: one slice",
+			chart:    pieChart(budget[:1], "division", "expended_amount"),
+			contains: []string{`<circle cx="110.0" cy="110.0" r="100.0"`, "33 (100.0%)"},
+			excludes: []string{"<path"},
+		},
+		{
+			name:     "pie",
+			chart:    pieChart(budget, "division", "expended_amount"),
+			contains: []string{"33 (75.0%)", "51 (25.0%)", "33: $300 (75.0%)"},
+		},
+		{
+			name:     "pie: other",
+			chart:    pieChart(programs, "program_code", "expended_amount"),
+			contains: []string{"P00 (15.4%)", "P08 (5.1%)", "Other (7.7%)"},
+			excludes: []string{"P09", "P11"},
+		},
+		{name: "line: nothing", chart: lineChart(nil, "period", "available_amount")},
+		{name: "line: one point", chart: lineChart(periods[:1], "period", "available_amount")},
+		{
+			name:  "line",
+			chart: lineChart(periods, "period", "available_amount"),
+			contains: []string{
+				// The highest value is at the top of the plot, and the lowest is at the bottom.
+				`points="70.0,230.0 640.0,10.0"`,
+				`>$1K<`,
+				`>$0<`,
+				"2024-08 Available Amount: $1K",
+			},
+		},
+	}
+	for _, row := range rows {
+		t.Run(row.name, func(t *testing.T) {
+			if row.contains == nil {
+				if row.chart != "" {
+					t.Errorf("Expected nothing to be drawn; got %s", row.chart)
+				}
+				return
+			}
+			for _, s := range row.contains {
+				if !strings.Contains(string(row.chart), s) {
+					t.Errorf("Expected the chart to contain %q; got %s", s, row.chart)
+				}
+			}
+			for _, s := range row.excludes {
+				if strings.Contains(string(row.chart), s) {
+					t.Errorf("Expected the chart not to contain %q; got %s", s, row.chart)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatChartMoney(t *testing.T) {
+	rows := []struct {
+		amount float64
+		output string
+	}{
+		{amount: 0, output: "$0"},
+		{amount: 999.4, output: "$999"},
+		{amount: 1600, output: "$2K"},
+		{amount: -25000, output: "$-25K"},
+		{amount: 1_260_000, output: "$1.3M"},
+	}
+	for _, row := range rows {
+		output := formatChartMoney(row.amount)
+		if output != row.output {
+			t.Errorf("%v: expected %q; got %q.", row.amount, row.output, output)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// defaultTemplates holds the built-in report definition.
+//
+//go:embed templates
+var defaultTemplates embed.FS
+
+// ReportDefinition describes the whole report.
+//
+// This is loaded from "report.json".
+type ReportDefinition struct {
+	Title        string              `json:"title"`
+	Template     string              `json:"template"`     // This is the template for the document itself; it is given the rendered sections.
+	Partials     []string            `json:"partials"`     // These templates are available to every section.
+	MoneyColumns []string            `json:"moneyColumns"` // These are the columns of the queries that hold money, as patterns such as "*_amount".
+	Sections     []SectionDefinition `json:"sections"`
+}
+
+// isMoneyColumn returns true if the column of a query holds money.
+func (d *ReportDefinition) isMoneyColumn(column string) bool {
+	for _, pattern := range d.MoneyColumns {
+		if ok, _ := path.Match(pattern, column); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SectionDefinition describes a single section of the report.
+type SectionDefinition struct {
+	Name     string            `json:"name"`
+	Title    string            `json:"title"`
+	Template string            `json:"template"`
+	Queries  map[string]string `json:"queries"` // This maps the name that the template uses for the rows to the SQL file.  Money stays in cents; "formatMoney" and the workbook show it as dollars.
+}
+
+// SectionData is what a section template is executed with.
+type SectionData struct {
+	Name       string
+	Title      string
+	Parameters map[string]any
+	Data       map[string][]Row
+}
+
+// RenderedSection is a section that has been rendered into HTML.
+type RenderedSection struct {
+	Name  string
+	Title string
+	HTML  template.HTML
+}
+
+// DocumentData is what the document template is executed with.
+type DocumentData struct {
+	Title      string
+	Parameters map[string]any
+	Sections   []RenderedSection
+}
+
+// overlayFS looks for files in the first file system, and then falls back to the second one.
+//
+// This lets a template directory replace or add only the files that it cares about.
+type overlayFS struct {
+	primary  fs.FS
+	fallback fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.primary != nil {
+		f, err := o.primary.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return o.fallback.Open(name)
+}
+
+// newTemplateFS returns the file system that holds the report definition.
+//
+// If a template directory is given, then its files take priority over the built-in ones.
+func newTemplateFS(templateDirectory string) (fs.FS, error) {
+	embedded, err := fs.Sub(defaultTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+	if templateDirectory == "" {
+		return embedded, nil
+	}
+	return overlayFS{primary: os.DirFS(templateDirectory), fallback: embedded}, nil
+}
+
+// loadReportDefinition reads "report.json" from the file system.
+func loadReportDefinition(fsys fs.FS) (*ReportDefinition, error) {
+	contents, err := fs.ReadFile(fsys, "report.json")
+	if err != nil {
+		return nil, fmt.Errorf("could not read report definition: %w", err)
+	}
+	var definition ReportDefinition
+	err = json.Unmarshal(contents, &definition)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse report definition: %w", err)
+	}
+	return &definition, nil
+}
+
+// runQueries runs every query for a section.
+func runQueries(db *gorm.DB, fsys fs.FS, section SectionDefinition, parameters map[string]any) (map[string][]Row, error) {
+	output := map[string][]Row{}
+	for name, filename := range section.Queries {
+		contents, err := fs.ReadFile(fsys, filename)
+		if err != nil {
+			return nil, fmt.Errorf("could not read query %q: %w", filename, err)
+		}
+
+		_, rows, err := queryRows(db, string(contents), parameters)
+		if err != nil {
+			return nil, fmt.Errorf("could not run query %q: %w", filename, err)
+		}
+		output[name] = rows
+	}
+	return output, nil
+}
+
+// queryRows runs a query and returns the columns in the order that the query returned them, along with the rows.
+func queryRows(db *gorm.DB, query string, parameters map[string]any) ([]string, []Row, error) {
+	// Gorm only treats the map as named parameters if the query actually uses any.
+	var args []any
+	if parameters != nil && strings.Contains(query, "@") {
+		args = append(args, parameters)
+	}
+
+	sqlRows, err := db.Raw(query, args...).Rows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer sqlRows.Close()
+
+	columns, err := sqlRows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows []Row
+	for sqlRows.Next() {
+		row := Row{}
+		err := db.ScanRows(sqlRows, &row)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	err = sqlRows.Err()
+	if err != nil {
+		return nil, nil, err
+	}
+	return columns, rows, nil
+}
+
+// renderSection runs the queries for a section and then executes its template.
+func renderSection(db *gorm.DB, fsys fs.FS, definition *ReportDefinition, section SectionDefinition, parameters map[string]any) (RenderedSection, error) {
+	data, err := runQueries(db, fsys, section, parameters)
+	if err != nil {
+		return RenderedSection{}, err
+	}
+
+	files := append([]string{}, definition.Partials...)
+	files = append(files, section.Template)
+	t, err := template.New(path.Base(section.Template)).Funcs(newFuncMap()).ParseFS(fsys, files...)
+	if err != nil {
+		return RenderedSection{}, fmt.Errorf("could not parse template %q: %w", section.Template, err)
+	}
+
+	var w bytes.Buffer
+	err = t.ExecuteTemplate(&w, path.Base(section.Template), SectionData{
+		Name:       section.Name,
+		Title:      section.Title,
+		Parameters: parameters,
+		Data:       data,
+	})
+	if err != nil {
+		return RenderedSection{}, fmt.Errorf("could not execute template %q: %w", section.Template, err)
+	}
+
+	return RenderedSection{
+		Name:  section.Name,
+		Title: section.Title,
+		HTML:  template.HTML(w.String()),
+	}, nil
+}
+
+// renderDocument renders every section and then puts them together with the document template.
+func renderDocument(db *gorm.DB, fsys fs.FS, definition *ReportDefinition, parameters map[string]any) ([]byte, error) {
+	documentData := DocumentData{
+		Title:      definition.Title,
+		Parameters: parameters,
+	}
+	for _, section := range definition.Sections {
+		fmt.Printf("Rendering section: %s\n", section.Name)
+		renderedSection, err := renderSection(db, fsys, definition, section, parameters)
+		if err != nil {
+			return nil, fmt.Errorf("section %q: %w", section.Name, err)
+		}
+		documentData.Sections = append(documentData.Sections, renderedSection)
+	}
+
+	files := append([]string{}, definition.Partials...)
+	files = append(files, definition.Template)
+	t, err := template.New(path.Base(definition.Template)).Funcs(newFuncMap()).ParseFS(fsys, files...)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse template %q: %w", definition.Template, err)
+	}
+
+	var w bytes.Buffer
+	err = t.ExecuteTemplate(&w, path.Base(definition.Template), documentData)
+	if err != nil {
+		return nil, fmt.Errorf("could not execute template %q: %w", definition.Template, err)
+	}
+	return w.Bytes(), nil
+}
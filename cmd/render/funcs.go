@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Row is a single row from a report query, keyed by column name.
+type Row = map[string]any
+
+// Group is a set of rows that share the same values for some columns.
+//
+// Templates use groups to build the division/unit/program hierarchy out of the flat query results.
+type Group struct {
+	Key  string
+	Rows []Row
+}
+
+// First returns the first row in the group.
+//
+// This is handy for the columns that are the same for every row in the group, such as descriptions.
+func (g Group) First() Row {
+	if len(g.Rows) == 0 {
+		return Row{}
+	}
+	return g.Rows[0]
+}
+
+// Sum returns the sum of the given column across every row in the group.
+func (g Group) Sum(column string) databasemodel.Money {
+	return sumRows(g.Rows, column)
+}
+
+// Group splits the rows in this group into smaller groups.
+func (g Group) Group(columns ...string) []Group {
+	return groupRows(g.Rows, columns...)
+}
+
+// Filter returns only the rows in this group where the given column is set to something other than a zero value.
+func (g Group) Filter(column string) Group {
+	output := Group{
+		Key: g.Key,
+	}
+	for _, row := range g.Rows {
+		if toFloat(row[column]) != 0 {
+			output.Rows = append(output.Rows, row)
+		}
+	}
+	return output
+}
+
+// groupRows groups the rows by the values of the given columns, keeping the order in which each group first appears.
+func groupRows(rows []Row, columns ...string) []Group {
+	var output []Group
+	indexMap := map[string]int{}
+	for _, row := range rows {
+		var parts []string
+		for _, column := range columns {
+			parts = append(parts, fmt.Sprintf("%v", row[column]))
+		}
+		key := strings.Join(parts, "-")
+
+		i, ok := indexMap[key]
+		if !ok {
+			i = len(output)
+			indexMap[key] = i
+			output = append(output, Group{Key: key})
+		}
+		output[i].Rows = append(output[i].Rows, row)
+	}
+	return output
+}
+
+// sumRows returns the sum of the given column across every row.
+//
+// Money is stored in cents, so the values are added up as whole cents.
+func sumRows(rows []Row, column string) databasemodel.Money {
+	var output databasemodel.Money
+	for _, row := range rows {
+		output += toMoney(row[column])
+	}
+	return output
+}
+
+// whereRows returns the rows where the given column matches the value.
+func whereRows(rows []Row, column string, value any) []Row {
+	var output []Row
+	for _, row := range rows {
+		if fmt.Sprintf("%v", row[column]) == fmt.Sprintf("%v", value) {
+			output = append(output, row)
+		}
+	}
+	return output
+}
+
+// toFloat converts whatever the database returned into a number.
+//
+// SQLite is loose with its types, so the same column may come back as an integer, a float, or a string.
+func toFloat(value any) float64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case float64:
+		return v
+	case databasemodel.Money:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	case []byte:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	default:
+		f, _ := strconv.ParseFloat(fmt.Sprintf("%v", v), 64)
+		return f
+	}
+}
+
+// toMoney converts a number of cents into money.
+//
+// The queries return money as whole cents, but anything that went through floating-point math is rounded to the
+// nearest cent.
+func toMoney(value any) databasemodel.Money {
+	switch v := value.(type) {
+	case databasemodel.Money:
+		return v
+	case int64:
+		return databasemodel.Money(v)
+	case int:
+		return databasemodel.Money(v)
+	case int32:
+		return databasemodel.Money(v)
+	default:
+		return databasemodel.Money(math.Round(toFloat(value)))
+	}
+}
+
+// toInt converts the value into an integer, if it is one.
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case databasemodel.Money:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// addAll adds the rest of the inputs to the first one, each multiplied by the sign.
+//
+// Money and counts are whole numbers, so if every input is one, then the math is done on integers.
+func addAll(sign int64, inputs ...any) any {
+	integers := true
+	for _, input := range inputs {
+		if _, ok := toInt(input); !ok {
+			integers = false
+		}
+	}
+
+	if integers {
+		var output int64
+		for i, input := range inputs {
+			v, _ := toInt(input)
+			if i > 0 {
+				v *= sign
+			}
+			output += v
+		}
+		return output
+	}
+
+	var output float64
+	for i, input := range inputs {
+		v := toFloat(input)
+		if i > 0 {
+			v *= float64(sign)
+		}
+		output += v
+	}
+	return output
+}
+
+// toTime converts whatever the database returned into a time.
+//
+// Dates come back as a time.Time when selected directly, but as a string when they are the result of an expression.
+func toTime(value any) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05-07:00", time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			t, err := time.Parse(layout, v)
+			if err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func newFuncMap() template.FuncMap {
+	return template.FuncMap{
+		"add": func(inputs ...any) any {
+			return addAll(1, inputs...)
+		},
+		"dict": func(inputs ...any) (map[string]any, error) {
+			if len(inputs)%2 != 0 {
+				return nil, fmt.Errorf("dict needs an even number of arguments")
+			}
+			output := map[string]any{}
+			for i := 0; i < len(inputs); i += 2 {
+				key, ok := inputs[i].(string)
+				if !ok {
+					return nil, fmt.Errorf("dict keys must be strings")
+				}
+				output[key] = inputs[i+1]
+			}
+			return output, nil
+		},
+		"div": func(inputs ...any) float64 {
+			if len(inputs) == 0 {
+				return 0
+			}
+			output := toFloat(inputs[0])
+			for i := 1; i < len(inputs); i++ {
+				output /= toFloat(inputs[i])
+			}
+			return output
+		},
+		"first": func(rows []Row) Row {
+			return Group{Rows: rows}.First()
+		},
+		"float": toFloat,
+		"formatDate": func(value any, layout string) string {
+			t := toTime(value)
+			if t.IsZero() {
+				return ""
+			}
+			return t.Format(layout)
+		},
+		"formatMoney": func(amount any) string {
+			// The amount is in cents; format the dollars and cents separately so that the cents are exactly what was stored.
+			cents := int64(toMoney(amount))
+			sign := ""
+			if cents < 0 {
+				sign = "-"
+				cents = -cents
+			}
+			printer := message.NewPrinter(language.English)
+			return "$" + sign + printer.Sprintf("%d", cents/100) + fmt.Sprintf(".%02d", cents%100)
+		},
+		"group":     groupRows,
+		"lineChart": lineChart,
+		"monthName": func(month any) string {
+			return time.Month(int(toFloat(month))).String()
+		},
+		"mul": func(inputs ...any) float64 {
+			if len(inputs) == 0 {
+				return 0
+			}
+			output := toFloat(inputs[0])
+			for i := 1; i < len(inputs); i++ {
+				output *= toFloat(inputs[i])
+			}
+			return output
+		},
+		"pieChart":        pieChart,
+		"stackedBarChart": stackedBarChart,
+		"sub": func(inputs ...any) any {
+			return addAll(-1, inputs...)
+		},
+		"sum":   sumRows,
+		"where": whereRows,
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+func TestFormatMoney(t *testing.T) {
+	rows := []struct {
+		amount any
+		output string
+	}{
+		{amount: databasemodel.Money(123456789), output: "$1,234,567.89"},
+		{amount: int64(-5), output: "$-0.05"},
+		{amount: int64(0), output: "$0.00"},
+		{amount: nil, output: "$0.00"},
+		// Anything that went through floating-point math is rounded to the nearest cent.
+		{amount: 1999.6, output: "$20.00"},
+	}
+	formatMoney := newFuncMap()["formatMoney"].(func(any) string)
+	for _, row := range rows {
+		output := formatMoney(row.amount)
+		if output != row.output {
+			t.Errorf("%#v: expected %q; got %q.", row.amount, row.output, output)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	rows := []struct {
+		sign   int64
+		inputs []any
+		output any
+	}{
+		{sign: 1, inputs: []any{int64(10), databasemodel.Money(20), 3}, output: int64(33)},
+		{sign: -1, inputs: []any{databasemodel.Money(100), int64(30), int64(20)}, output: int64(50)},
+		{sign: -1, inputs: []any{int64(5)}, output: int64(5)},
+		{sign: 1, inputs: nil, output: int64(0)},
+		// Anything that is not a whole number is done in floating point.
+		{sign: 1, inputs: []any{int64(1), 0.5}, output: 1.5},
+		{sign: -1, inputs: []any{0.5, int64(1)}, output: -0.5},
+	}
+	for _, row := range rows {
+		output := addAll(row.sign, row.inputs...)
+		if output != row.output {
+			t.Errorf("%d %v: expected %#v; got %#v.", row.sign, row.inputs, row.output, output)
+		}
+	}
+}
+
+func TestSumRows(t *testing.T) {
+	rows := []Row{
+		{"amount": int64(10)},
+		{"amount": databasemodel.Money(20)},
+		{"amount": nil},
+		{},
+	}
+	if output := sumRows(rows, "amount"); output != 30 {
+		t.Errorf("Expected 30 cents; got %v.", output)
+	}
+}
@@ -1,608 +1,143 @@
 package main
 
 import (
-	"bytes"
 	"flag"
-	"html/template"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/tekkamanendless/cboc-tools/database"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
 )
 
 func main() {
 	var outputDirectory string
 	var databaseFile string
+	var templateDirectory string
+	var format string
+	var targetYear int
+	var targetMonth int
+	var expiringWithin time.Duration
+	var fiscalYearStartMonth int
+	var reconciliationThreshold float64
 	flag.StringVar(&outputDirectory, "output-directory", "", "The location to save the results.")
 	flag.StringVar(&databaseFile, "database-file", "", "The database file.")
+	flag.StringVar(&templateDirectory, "template-directory", "", "A directory with a report definition (\"report.json\"), SQL files, and templates.  Any file not found here is taken from the built-in report.")
+	flag.StringVar(&format, "format", "html", "The output format: \"html\", \"pdf\", or \"xlsx\".")
+	flag.IntVar(&targetYear, "target-year", 0, "The target year.  If not set, then the most recent period in the database is used.")
+	flag.IntVar(&targetMonth, "target-month", 0, "The target month.  If not set, then the most recent period in the database is used.")
+	flag.IntVar(&fiscalYearStartMonth, "fiscal-year-start-month", 7, "The first month of the fiscal year.  This must match the flag of the same name given to the \"parse-reports\" command.")
+	flag.DurationVar(&expiringWithin, "expiring-within", 90*24*time.Hour, "Appropriations that end within this long after the report date are called out as expiring.")
+	flag.Float64Var(&reconciliationThreshold, "reconciliation-threshold", 1.00, "Differences between FSF and Mobius of more than this many dollars are called out as discrepancies.")
 
 	flag.Parse()
 
+	if fiscalYearStartMonth < 1 || fiscalYearStartMonth > 12 {
+		panic(fmt.Errorf("the fiscal year start month must be from 1 to 12: %d", fiscalYearStartMonth))
+	}
+
 	db, err := database.New("file:" + databaseFile)
 	if err != nil {
 		panic(err)
 	}
 
-	var allHTML string
-	allHTML += "<html>"
-	allHTML += "<head>"
-	allHTML += "<title>CBOC Report</title>"
-	allHTML += `<style>
-body {
-	font-family: sans-serif;
-}
-@media screen {
-	.page {
-		padding-bottom: 5em;
-		border-bottom: 1px solid gray;
-		margin-bottom: 5em;
-	}
-}
-@media print {
-	body {
-		font-size: 0.9em;
-	}
-	td, th {
-		font-size: 0.9em;
-	}
-	.page {
-		break-after: page;
-	}
-	.page-break {
-		break-after: page;
-	}
-}
-a:visited, a:active {
-	color: blue;
-}
-.money {
-	text-align: right;
-	font-variant-numeric: ordinal;
-	font-size: 0.9em;
-}
-.budget-bar {
-	display: flex;
-	min-height: 1em;
-	height: 1em;
-	background-color: #f0f0f0;
-}
-.budget-bar div {
-	min-height: 1em;
-	height: 1em;
-}
-.expended {
-	background-color: #ff0000;
-}
-.encumbered {
-	background-color: #ff8800;
-}
-.available {
-	background-color: #88ff88;
-}
-</style>`
-	allHTML += "</head>"
-	allHTML += "<body>"
-	allHTML += "<h1>CBOC Report</h1>"
-
-	funcMap := template.FuncMap{
-		"add": func(inputs ...float64) float64 {
-			if len(inputs) == 0 {
-				return 0
-			}
-			output := inputs[0]
-			for i := 1; i < len(inputs); i++ {
-				output += inputs[i]
-			}
-			return output
-		},
-		"div": func(inputs ...float64) float64 {
-			if len(inputs) == 0 {
-				return 0
-			}
-			output := inputs[0]
-			for i := 1; i < len(inputs); i++ {
-				output /= inputs[i]
-			}
-			return output
-		},
-		"formatMoney": func(amount float64) string {
-			printer := message.NewPrinter(language.English)
-			return "$" + printer.Sprintf("%0.2f", amount)
-		},
-		"mul": func(inputs ...float64) float64 {
-			if len(inputs) == 0 {
-				return 0
-			}
-			output := inputs[0]
-			for i := 1; i < len(inputs); i++ {
-				output *= inputs[i]
-			}
-			return output
-		},
-		"sub": func(inputs ...float64) float64 {
-			if len(inputs) == 0 {
-				return 0
-			}
-			output := inputs[0]
-			for i := 1; i < len(inputs); i++ {
-				output -= inputs[i]
-			}
-			return output
-		},
-	}
-
-	{
-		allHTML += `<div class="page">`
-		allHTML += "<h1>Table of Contents</h1>"
-		allHTML += "<ul>"
-		allHTML += "<li><a href=\"#budget-overview\">Budget Overview</a></li>"
-		allHTML += "<li><a href=\"#budget-breakdown\">Budget Breakdown</a></li>"
-		allHTML += "<li><a href=\"#program-breakdown\">Program Breakdown</a></li>"
-		allHTML += "</ul>"
-		allHTML += `</div>`
-	}
-
-	{
+	// The database can hold many periods side by side, so only report on one of them.
+	if targetYear == 0 || targetMonth == 0 {
 		type Row struct {
-			Division              string  `gorm:"column:division"`
-			DepartmentDescription string  `gorm:"column:department_description"`
-			BudgetAmount          float64 `gorm:"column:budget_amount"`
-			EncumberedAmount      float64 `gorm:"column:encumbered_amount"`
-			ExpendedAmount        float64 `gorm:"column:expended_amount"`
+			Year  int `gorm:"column:period_year"`
+			Month int `gorm:"column:period_month"`
 		}
 		var rows []Row
 		err := db.Raw(`
 SELECT
-	report.division,
-	department.department_description,
-	SUM(budget_amount) AS budget_amount,
-	SUM(encumbered_amount) AS encumbered_amount,
-	SUM(expended_amount) AS expended_amount
+	period_year,
+	period_month
 FROM
-	fsf_operating_unit_expenditure_summaries AS report
-	INNER JOIN
-	(
-		SELECT DISTINCT division, department_description FROM mobius_dgl115
-	) AS department
-		ON report.division = department.division
-GROUP BY
-	report.division
-HAVING
-	budget_amount > 0
+	fsf_operating_unit_expenditure_summaries
+ORDER BY
+	period_year DESC, period_month DESC
+LIMIT 1
 `).
 			Find(&rows).
 			Error
 		if err != nil {
 			panic(err)
 		}
-
-		templateText := `
-<a name="budget-overview">
-<h1>Budget Overview</h1>
-<table width="100%">
-	<thead>
-		<tr>
-			<th width="40%">Department</th>
-			<th width="10%">Budget Amount</th>
-			<th width="40%">Usage</th>
-			<th width="10%">Available</th>
-		</tr>
-	</thead>
-	<tbody>
-{{ range . }}
-		<tr>
-			<td><a href="#budget-breakdown-{{ .Division }}">{{ .Division }} - {{ .DepartmentDescription }}</a></td>
-			<td><div class="money">{{ formatMoney .BudgetAmount }}</div></td>
-			<td><div style="width: 100%;" class="budget-bar"><div class="expended" style="width: {{ div ( mul 100 .ExpendedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .ExpendedAmount }}"></div><div class="encumbered" style="width: {{ div ( mul 100.0 .EncumberedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .EncumberedAmount }}"></div><div class="available" style="flex: 1;" title="{{ formatMoney (sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}"></div></td>
-			<td><div class="money">{{ formatMoney ( sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}</div></td>
-		</tr>
-{{ end }}
-	</tbody>
-</table>
-                `
-		t, err := template.New("").Funcs(funcMap).Parse(templateText)
-		if err != nil {
-			panic(err)
-		}
-
-		var w bytes.Buffer
-		err = t.Execute(&w, rows)
-		if err != nil {
-			panic(err)
+		if len(rows) > 0 {
+			targetYear = rows[0].Year
+			targetMonth = rows[0].Month
 		}
-
-		allHTML += `<div class="page">`
-		allHTML += w.String()
-		allHTML += `</div>`
 	}
+	fmt.Printf("Target period: %d-%02d\n", targetYear, targetMonth)
 
-	{
-		type Line struct {
-			ProgramCode            string
-			ProgramCodeDescription string
-			BudgetAmount           float64
-			EncumberedAmount       float64
-			ExpendedAmount         float64
-		}
-
-		type Unit struct {
-			UnitCode        string
-			UnitDescription string
-			Lines           []*Line
+	// The fiscal year that the target period falls in started on the most recent start month.
+	fiscalStartYear := targetYear
+	if targetMonth < fiscalYearStartMonth {
+		fiscalStartYear--
+	}
+	monthsElapsed := (targetYear-fiscalStartYear)*12 + targetMonth - fiscalYearStartMonth + 1
 
-			BudgetAmount     float64
-			EncumberedAmount float64
-			ExpendedAmount   float64
-		}
+	templateFS, err := newTemplateFS(templateDirectory)
+	if err != nil {
+		panic(err)
+	}
 
-		type Division struct {
-			Division    string
-			Description string
-			Units       []*Unit
+	definition, err := loadReportDefinition(templateFS)
+	if err != nil {
+		panic(err)
+	}
 
-			BudgetAmount     float64
-			EncumberedAmount float64
-			ExpendedAmount   float64
-		}
+	// These are available to every query as "@name" and to every template as ".Parameters.name".
+	parameters := map[string]any{
+		"target_year":              targetYear,
+		"target_month":             targetMonth,
+		"fiscal_start_year":        fiscalStartYear,
+		"fiscal_start_month":       fiscalYearStartMonth,
+		"fiscal_year_elapsed":      float64(monthsElapsed) / 12,
+		"fiscal_months_elapsed":    monthsElapsed,
+		"expiring_within_days":     expiringWithin.Hours() / 24,
+		"reconciliation_threshold": databasemodel.MoneyFromFloat(reconciliationThreshold),
+		"generated_at":             time.Now(),
+	}
 
-		type Row struct {
-			Division               string  `gorm:"column:division"`
-			DepartmentDescription  string  `gorm:"column:department_description"`
-			UnitCode               string  `gorm:"column:operating_unit"`
-			UnitDescription        string  `gorm:"column:operating_unit_description"`
-			ProgramCode            string  `gorm:"column:program_code"`
-			ProgramCodeDescription string  `gorm:"column:program_code_description"`
-			BudgetAmount           float64 `gorm:"column:budget_amount"`
-			EncumberedAmount       float64 `gorm:"column:encumbered_amount"`
-			ExpendedAmount         float64 `gorm:"column:expended_amount"`
-		}
-		var rows []Row
-		err := db.Raw(`
-SELECT
-	report.division,
-	department.department_description,
-	operating_unit,
-	operating_unit_description,
-	program_code,
-	program_code_description,
-	SUM(budget_amount) AS budget_amount,
-	SUM(encumbered_amount) AS encumbered_amount,
-	SUM(expended_amount) AS expended_amount
-FROM
-	fsf_operating_unit_program_summaries AS report
-	INNER JOIN
-	(
-		SELECT DISTINCT division, department_description FROM mobius_dgl115
-	) AS department
-		ON report.division = department.division
-GROUP BY
-	report.division, operating_unit, program_code
-HAVING
-	budget_amount > 0
-`).
-			Find(&rows).
-			Error
+	switch format {
+	case "html":
+		contents, err := renderDocument(db, templateFS, definition, parameters)
 		if err != nil {
 			panic(err)
 		}
 
-		divisionMap := map[string]*Division{}
-		unitMap := map[string]map[string]*Unit{}
-		divisions := []*Division{}
-		for _, row := range rows {
-			division, ok := divisionMap[row.Division]
-			if !ok {
-				division = &Division{
-					Division:    row.Division,
-					Description: row.DepartmentDescription,
-				}
-				divisionMap[row.Division] = division
-				divisions = append(divisions, division)
-			}
-
-			if _, ok := unitMap[row.Division]; !ok {
-				unitMap[row.Division] = map[string]*Unit{}
-			}
-			unit, ok := unitMap[row.Division][row.UnitCode]
-			if !ok {
-				unit = &Unit{
-					UnitCode:        row.UnitCode,
-					UnitDescription: row.UnitDescription,
-				}
-				unitMap[row.Division][row.UnitCode] = unit
-				division.Units = append(division.Units, unit)
-			}
-
-			line := &Line{
-				ProgramCode:            row.ProgramCode,
-				ProgramCodeDescription: row.ProgramCodeDescription,
-				BudgetAmount:           row.BudgetAmount,
-				EncumberedAmount:       row.EncumberedAmount,
-				ExpendedAmount:         row.ExpendedAmount,
-			}
-			unit.Lines = append(unit.Lines, line)
-
-			unit.BudgetAmount += row.BudgetAmount
-			unit.EncumberedAmount += row.EncumberedAmount
-			unit.ExpendedAmount += row.ExpendedAmount
-
-			division.BudgetAmount += row.BudgetAmount
-			division.EncumberedAmount += row.EncumberedAmount
-			division.ExpendedAmount += row.ExpendedAmount
-		}
-
-		templateText := `
-<a name="budget-breakdown">
-<h1>Budget Breakdown</h1>
-{{ range . }}
-{{ $division := .}}
-<div class="page">
-<a name="budget-breakdown-{{ .Division }}">
-<h2>{{ .Division }} - {{ .Description }}</h2>
-<table width="100%">
-	<thead>
-		<tr>
-			<th width="10%">Code</th>
-			<th width="30%">Program</th>
-			<th width="10%">Budget Amount</th>
-			<th width="40%">Usage</th>
-			<th width="10%">Available</th>
-		</tr>
-	</thead>
-	<tbody>
-{{ range .Units }}
-		<tr>
-			<td><a href="#budget-breakdown-{{ $division.Division }}-unit-{{ .UnitCode }}">{{ .UnitCode }}</a></td>
-			<td><a href="#budget-breakdown-{{ $division.Division }}-unit-{{ .UnitCode }}">{{ .UnitDescription }}</a></td>
-			<td><div class="money">{{ formatMoney .BudgetAmount }}</div></td>
-			<td><div style="width: 100%;" class="budget-bar"><div class="expended" style="width: {{ div ( mul 100 .ExpendedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .ExpendedAmount }}"></div><div class="encumbered" style="width: {{ div ( mul 100.0 .EncumberedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .EncumberedAmount }}"></div><div class="available" style="flex: 1;" title="{{ formatMoney (sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}"></div></td>
-			<td><div class="money">{{ formatMoney ( sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}</div></td>
-		</tr>
-{{ end }}
-	</tbody>
-</table>
-{{ range .Units }}
- <a name="budget-breakdown-{{ $division.Division }}-unit-{{ .UnitCode }}">
-<h3>{{ .UnitCode }} - {{ .UnitDescription }}</h3>
-<table width="100%">
-	<thead>
-		<tr>
-			<th width="10%">Code</th>
-			<th width="30%">Program</th>
-			<th width="10%">Budget Amount</th>
-			<th width="40%">Usage</th>
-			<th width="10%">Available</th>
-		</tr>
-	</thead>
-	<tbody>
-{{ range .Lines }}
-		<tr>
-			<td>{{ .ProgramCode }}</td>
-			<td>{{ .ProgramCodeDescription }}</td>
-			<td><div class="money">{{ formatMoney .BudgetAmount }}</div></td>
-			<td><div style="width: 100%;" class="budget-bar"><div class="expended" style="width: {{ div ( mul 100 .ExpendedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .ExpendedAmount }}"></div><div class="encumbered" style="width: {{ div ( mul 100.0 .EncumberedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .EncumberedAmount }}"></div><div class="available" style="flex: 1;" title="{{ formatMoney (sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}"></div></td>
-			<td><div class="money">{{ formatMoney ( sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}</div></td>
-		</tr>
-{{ end }}
-	</tbody>
-</table>
-{{ end }}
-</div>
-{{ end }}
-                `
-		t, err := template.New("").Funcs(funcMap).Parse(templateText)
+		err = os.WriteFile(outputDirectory+string(filepath.Separator)+"report.html", contents, 0644)
 		if err != nil {
 			panic(err)
 		}
-
-		var w bytes.Buffer
-		err = t.Execute(&w, divisions)
+	case "pdf":
+		contents, err := renderDocument(db, templateFS, definition, parameters)
 		if err != nil {
 			panic(err)
 		}
 
-		//allHTML += `<div class="page">`
-		allHTML += w.String()
-		//allHTML += `</div>`
-	}
-
-	{
-		type Line struct {
-			UnitCode            string
-			UnitCodeDescription string
-			BudgetAmount        float64
-			EncumberedAmount    float64
-			ExpendedAmount      float64
-		}
-
-		type Program struct {
-			ProgramCode            string
-			ProgramCodeDescription string
-			Lines                  []*Line
-
-			BudgetAmount     float64
-			EncumberedAmount float64
-			ExpendedAmount   float64
-		}
-
-		type Division struct {
-			Division    string
-			Description string
-			Programs    []*Program
-
-			BudgetAmount     float64
-			EncumberedAmount float64
-			ExpendedAmount   float64
-		}
-
-		type Row struct {
-			Division               string  `gorm:"column:division"`
-			DepartmentDescription  string  `gorm:"column:department_description"`
-			UnitCode               string  `gorm:"column:operating_unit"`
-			UnitDescription        string  `gorm:"column:operating_unit_description"`
-			ProgramCode            string  `gorm:"column:program_code"`
-			ProgramCodeDescription string  `gorm:"column:program_code_description"`
-			BudgetAmount           float64 `gorm:"column:budget_amount"`
-			EncumberedAmount       float64 `gorm:"column:encumbered_amount"`
-			ExpendedAmount         float64 `gorm:"column:expended_amount"`
-		}
-		var rows []Row
-		err := db.Raw(`
-SELECT
-	report.division,
-	department.department_description,
-	operating_unit,
-	operating_unit_description,
-	program_code,
-	program_code_description,
-	SUM(budget_amount) AS budget_amount,
-	SUM(encumbered_amount) AS encumbered_amount,
-	SUM(expended_amount) AS expended_amount
-FROM
-	fsf_operating_unit_program_summaries AS report
-	INNER JOIN
-	(
-		SELECT DISTINCT division, department_description FROM mobius_dgl115
-	) AS department
-		ON report.division = department.division
-GROUP BY
-	report.division, operating_unit, program_code
-HAVING
-	budget_amount > 0
-`).
-			Find(&rows).
-			Error
+		contents, err = renderPDF(contents)
 		if err != nil {
 			panic(err)
 		}
 
-		divisionMap := map[string]*Division{}
-		programMap := map[string]map[string]*Program{}
-		divisions := []*Division{}
-		for _, row := range rows {
-			division, ok := divisionMap[row.Division]
-			if !ok {
-				division = &Division{
-					Division:    row.Division,
-					Description: row.DepartmentDescription,
-				}
-				divisionMap[row.Division] = division
-				divisions = append(divisions, division)
-			}
-
-			if _, ok := programMap[row.Division]; !ok {
-				programMap[row.Division] = map[string]*Program{}
-			}
-			program, ok := programMap[row.Division][row.ProgramCode]
-			if !ok {
-				program = &Program{
-					ProgramCode:            row.ProgramCode,
-					ProgramCodeDescription: row.ProgramCodeDescription,
-				}
-				programMap[row.Division][row.ProgramCode] = program
-				division.Programs = append(division.Programs, program)
-			}
-
-			line := &Line{
-				UnitCode:            row.UnitCode,
-				UnitCodeDescription: row.UnitDescription,
-				BudgetAmount:        row.BudgetAmount,
-				EncumberedAmount:    row.EncumberedAmount,
-				ExpendedAmount:      row.ExpendedAmount,
-			}
-			program.Lines = append(program.Lines, line)
-
-			program.BudgetAmount += row.BudgetAmount
-			program.EncumberedAmount += row.EncumberedAmount
-			program.ExpendedAmount += row.ExpendedAmount
-
-			division.BudgetAmount += row.BudgetAmount
-			division.EncumberedAmount += row.EncumberedAmount
-			division.ExpendedAmount += row.ExpendedAmount
+		err = os.WriteFile(outputDirectory+string(filepath.Separator)+"report.pdf", contents, 0644)
+		if err != nil {
+			panic(err)
 		}
-
-		templateText := `
-<a name="program-breakdown">
-<h1>Program Breakdown</h1>
-{{ range . }}
-{{ $division := .}}
-<div class="page">
-<a name="program-breakdown-{{ .Division }}">
-<h2>{{ .Division }} - {{ .Description }}</h2>
-<table width="100%">
-	<thead>
-		<tr>
-			<th width="10%">Code</th>
-			<th width="30%">Program</th>
-			<th width="10%">Budget Amount</th>
-			<th width="40%">Usage</th>
-			<th width="10%">Available</th>
-		</tr>
-	</thead>
-	<tbody>
-{{ range .Programs }}
-		<tr>
-			<td><a href="#program-breakdown-{{ $division.Division }}-unit-{{ .ProgramCode }}">{{ .ProgramCode }}</a></td>
-			<td><a href="#program-breakdown-{{ $division.Division }}-unit-{{ .ProgramCode }}">{{ .ProgramCodeDescription }}</a></td>
-			<td><div class="money">{{ formatMoney .BudgetAmount }}</div></td>
-			<td><div style="width: 100%;" class="budget-bar"><div class="expended" style="width: {{ div ( mul 100 .ExpendedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .ExpendedAmount }}"></div><div class="encumbered" style="width: {{ div ( mul 100.0 .EncumberedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .EncumberedAmount }}"></div><div class="available" style="flex: 1;" title="{{ formatMoney (sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}"></div></td>
-			<td><div class="money">{{ formatMoney ( sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}</div></td>
-		</tr>
-{{ end }}
-	</tbody>
-</table>
-{{ range .Programs }}
- <a name="program-breakdown-{{ $division.Division }}-unit-{{ .ProgramCode }}">
-<h3>{{ .ProgramCode }} - {{ .ProgramCodeDescription }}</h3>
-<table width="100%">
-	<thead>
-		<tr>
-			<th width="10%">Code</th>
-			<th width="30%">Unit</th>
-			<th width="10%">Budget Amount</th>
-			<th width="40%">Usage</th>
-			<th width="10%">Available</th>
-		</tr>
-	</thead>
-	<tbody>
-{{ range .Lines }}
-		<tr>
-			<td>{{ .UnitCode }}</td>
-			<td>{{ .UnitCodeDescription }}</td>
-			<td><div class="money">{{ formatMoney .BudgetAmount }}</div></td>
-			<td><div style="width: 100%;" class="budget-bar"><div class="expended" style="width: {{ div ( mul 100 .ExpendedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .ExpendedAmount }}"></div><div class="encumbered" style="width: {{ div ( mul 100.0 .EncumberedAmount ) .BudgetAmount }}%;" title="{{ formatMoney .EncumberedAmount }}"></div><div class="available" style="flex: 1;" title="{{ formatMoney (sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}"></div></td>
-			<td><div class="money">{{ formatMoney ( sub .BudgetAmount .ExpendedAmount .EncumberedAmount ) }}</div></td>
-		</tr>
-{{ end }}
-	</tbody>
-</table>
-{{ end }}
-</div>
-{{ end }}
-                `
-		t, err := template.New("").Funcs(funcMap).Parse(templateText)
+	case "xlsx":
+		contents, err := renderXLSX(db, templateFS, definition, parameters)
 		if err != nil {
 			panic(err)
 		}
 
-		var w bytes.Buffer
-		err = t.Execute(&w, divisions)
+		err = os.WriteFile(outputDirectory+string(filepath.Separator)+"report.xlsx", contents, 0644)
 		if err != nil {
 			panic(err)
 		}
-
-		//allHTML += `<div class="page">`
-		allHTML += w.String()
-		//allHTML += `</div>`
-	}
-
-	allHTML += "</body>"
-	allHTML += "</html>"
-
-	err = os.WriteFile(outputDirectory+string(filepath.Separator)+"report.html", []byte(allHTML), 0644)
-	if err != nil {
-		panic(err)
+	default:
+		panic(fmt.Errorf("unsupported format: %s", format))
 	}
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+// pdfFooterTemplate is printed at the bottom of every page of the PDF.
+//
+// Chrome fills in the "pageNumber" and "totalPages" classes.
+const pdfFooterTemplate = `<div style="font-size: 8px; width: 100%; text-align: center;"><span class="pageNumber"></span> of <span class="totalPages"></span></div>`
+
+// renderPDF prints the HTML report to a PDF using a headless browser.
+//
+// The links in the table of contents stay clickable, and the headings become the PDF outline.
+func renderPDF(html []byte) ([]byte, error) {
+	// The browser needs to load the report from somewhere, so write it to a temporary file.
+	tempDirectory, err := os.MkdirTemp("", "cboc-render-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	htmlFile := tempDirectory + string(filepath.Separator) + "report.html"
+	err = os.WriteFile(htmlFile, html, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not write temporary file: %w", err)
+	}
+
+	l := launcher.New().
+		Headless(true)
+	defer l.Cleanup()
+
+	controlURL, err := l.Launch()
+	if err != nil {
+		return nil, fmt.Errorf("could not launch browser: %w", err)
+	}
+
+	browser := rod.New().ControlURL(controlURL)
+	err = browser.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to browser: %w", err)
+	}
+	defer browser.Close()
+
+	page, err := browser.Page(proto.TargetCreateTarget{URL: "file://" + filepath.ToSlash(htmlFile)})
+	if err != nil {
+		return nil, fmt.Errorf("could not load report: %w", err)
+	}
+	err = page.WaitLoad()
+	if err != nil {
+		return nil, fmt.Errorf("could not wait for report to load: %w", err)
+	}
+
+	marginTop := 0.5
+	marginBottom := 0.6
+	reader, err := page.PDF(&proto.PagePrintToPDF{
+		DisplayHeaderFooter:     true,
+		PrintBackground:         true,
+		MarginTop:               &marginTop,
+		MarginBottom:            &marginBottom,
+		HeaderTemplate:          `<div></div>`,
+		FooterTemplate:          pdfFooterTemplate,
+		GenerateTaggedPDF:       true,
+		GenerateDocumentOutline: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not print PDF: %w", err)
+	}
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not read PDF: %w", err)
+	}
+	return contents, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDatabase opens an empty database file with all of the tables.
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Could not open the database: %v", err)
+	}
+	err = databasemodel.Apply(db)
+	if err != nil {
+		t.Fatalf("Could not apply the model: %v", err)
+	}
+	return db
+}
+
+// testParameters are the parameters for September 2024, three months into a fiscal year that starts in July.
+func testParameters() map[string]any {
+	return map[string]any{
+		"target_year":              2024,
+		"target_month":             9,
+		"fiscal_start_year":        2024,
+		"fiscal_start_month":       7,
+		"fiscal_year_elapsed":      0.25,
+		"fiscal_months_elapsed":    3,
+		"expiring_within_days":     90.0,
+		"reconciliation_threshold": databasemodel.Money(100),
+	}
+}
+
+// createImport adds an import of the given report for a period, along with its records.
+func createImport[T any](t *testing.T, db *gorm.DB, reportType string, year int, month int, records []T, setImportID func(*T, uint)) {
+	t.Helper()
+
+	importRecord := databasemodel.Import{ReportType: reportType, Year: year, Month: month, RecordCount: len(records)}
+	err := db.Create(&importRecord).Error
+	if err != nil {
+		t.Fatalf("Could not create the import: %v", err)
+	}
+	for i := range records {
+		setImportID(&records[i], importRecord.ID)
+	}
+	err = db.Create(&records).Error
+	if err != nil {
+		t.Fatalf("Could not create the records: %v", err)
+	}
+}
+
+// runSectionQuery runs one of the built-in queries.
+func runSectionQuery(t *testing.T, db *gorm.DB, filename string, parameters map[string]any) []Row {
+	t.Helper()
+
+	fsys, err := newTemplateFS("")
+	if err != nil {
+		t.Fatalf("Could not open the templates: %v", err)
+	}
+	contents, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		t.Fatalf("Could not read %s: %v", filename, err)
+	}
+	_, rows, err := queryRows(db, string(contents), parameters)
+	if err != nil {
+		t.Fatalf("Could not run %s: %v", filename, err)
+	}
+	return rows
+}
+
+func TestReconciliationIncompleteMonths(t *testing.T) {
+	db := openTestDatabase(t)
+
+	createImport(t, db, "mobius.DGL060", 2024, 9, []databasemodel.MobiusDGL060{
+		{Division: "33", Fund: "100", CurrentYearExpenses: 30000},
+	}, func(record *databasemodel.MobiusDGL060, importID uint) { record.ImportID = importID })
+	detailedActivity := func(record *databasemodel.FSFDetailedActivity, importID uint) { record.ImportID = importID }
+	createImport(t, db, "fsf.detailed-activity-report", 2024, 7, []databasemodel.FSFDetailedActivity{
+		{Division: "33", Fund: "100", Amount: 10000},
+	}, detailedActivity)
+	createImport(t, db, "fsf.detailed-activity-report", 2024, 9, []databasemodel.FSFDetailedActivity{
+		{Division: "33", Fund: "100", Amount: 10000},
+	}, detailedActivity)
+
+	fundRow := func() Row {
+		for _, row := range runSectionQuery(t, db, "reconciliation.sql", testParameters()) {
+			if row["fund"] == "100" {
+				return row
+			}
+		}
+		t.Fatalf("There is no comparison for fund 100.")
+		return nil
+	}
+
+	// August is missing, so the FSF side is short by a month; that is not a discrepancy.
+	row := fundRow()
+	if toFloat(row["incomplete"]) != 1 || toFloat(row["discrepancy"]) != 0 {
+		t.Errorf("With a month missing: expected an incomplete comparison and no discrepancy; got %v.", row)
+	}
+	if toFloat(row["fsf_months"]) != 2 || toFloat(row["expected_months"]) != 3 {
+		t.Errorf("With a month missing: expected 2 of 3 months; got %v of %v.", row["fsf_months"], row["expected_months"])
+	}
+
+	createImport(t, db, "fsf.detailed-activity-report", 2024, 8, []databasemodel.FSFDetailedActivity{
+		{Division: "33", Fund: "100", Amount: 10000},
+	}, detailedActivity)
+	row = fundRow()
+	if toFloat(row["incomplete"]) != 0 || toFloat(row["discrepancy"]) != 0 {
+		t.Errorf("With every month: expected a complete comparison and no discrepancy; got %v.", row)
+	}
+}
+
+func TestRenderDocument(t *testing.T) {
+	db := openTestDatabase(t)
+
+	createImport(t, db, "mobius.DGL060", 2024, 9, []databasemodel.MobiusDGL060{
+		{Division: "33", Fund: "100", Appropriation: "10000", AvailableAmount: 100000, EncumberedAmount: 2500, CurrentYearExpenses: 30000},
+	}, func(record *databasemodel.MobiusDGL060, importID uint) { record.ImportID = importID })
+	createImport(t, db, "fsf.detailed-activity-report", 2024, 9, []databasemodel.FSFDetailedActivity{
+		{Division: "33", Fund: "100", Amount: 10000},
+	}, func(record *databasemodel.FSFDetailedActivity, importID uint) { record.ImportID = importID })
+
+	fsys, err := newTemplateFS("")
+	if err != nil {
+		t.Fatalf("Could not open the templates: %v", err)
+	}
+	definition, err := loadReportDefinition(fsys)
+	if err != nil {
+		t.Fatalf("Could not load the report definition: %v", err)
+	}
+	output, err := renderDocument(db, fsys, definition, testParameters())
+	if err != nil {
+		t.Fatalf("Could not render the report: %v", err)
+	}
+	// The money is stored in cents, but it is shown in dollars.
+	if !strings.Contains(string(output), "$300.00") {
+		t.Errorf("Expected the expended amount to be shown in dollars.")
+	}
+}
+
+// createFixture adds a small set of reports for division 33 for the first three months of fiscal 2025, along with an
+// import for the month after the target period that every query should ignore.
+func createFixture(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	createImport(t, db, "mobius.DGL115", 2024, 9, []databasemodel.MobiusDGL115{
+		{Division: "33", DepartmentDescription: "Christina", FiscalYear: 2025, AccountPeriod: 3, Account: "5000", LocalFundsYearToDate: 20000, StateFundsYearToDate: 10000, TotalFundsYearToDate: 30000},
+	}, func(record *databasemodel.MobiusDGL115, importID uint) { record.ImportID = importID })
+	createImport(t, db, "mobius.DGL114", 2024, 9, []databasemodel.MobiusDGL114{
+		{Division: "33", BudgetYear: 2025, Fund: "100", RevenueAccount: "4000", LocalFundsYearToDate: 50000, StateFundsYearToDate: 25000},
+	}, func(record *databasemodel.MobiusDGL114, importID uint) { record.ImportID = importID })
+	createImport(t, db, "mobius.DGL060", 2024, 9, []databasemodel.MobiusDGL060{
+		{Division: "33", FiscalYear: 2025, Fund: "100", Appropriation: "10000", EndDate: time.Date(2024, 11, 30, 0, 0, 0, 0, time.UTC), AvailableAmount: 100000, EncumberedAmount: 2500, CurrentYearExpenses: 30000, RemainingAmount: 67500},
+	}, func(record *databasemodel.MobiusDGL060, importID uint) { record.ImportID = importID })
+	createImport(t, db, "fsf.detailed-activity-report", 2024, 9, []databasemodel.FSFDetailedActivity{
+		{Division: "33", Fund: "100", Account: "5000", Amount: 30000},
+	}, func(record *databasemodel.FSFDetailedActivity, importID uint) { record.ImportID = importID })
+
+	programSummary := func(record *databasemodel.FSFOperatingUnitProgramSummary, importID uint) { record.ImportID = importID }
+	createImport(t, db, "fsf.operating-unit-program-summary", 2024, 9, []databasemodel.FSFOperatingUnitProgramSummary{
+		// At a quarter of the way through the year, this is on pace to spend four times what it has so far.
+		{Year: 2024, Month: 9, Division: "33", OperatingUnit: "1000", ProgramCode: "A", BudgetedAmount: 100000, ExpendedAmount: 30000},
+		// This has already committed more than its pace would say.
+		{Year: 2024, Month: 9, Division: "33", OperatingUnit: "1000", ProgramCode: "B", BudgetedAmount: 100000, EncumberedAmount: 50000, ExpendedAmount: 10000},
+		// These have no budget, so there is nothing to project against.
+		{Year: 2024, Month: 9, Division: "33", OperatingUnit: "1000", ProgramCode: "C", ExpendedAmount: 5000},
+		{Year: 2024, Month: 9, Division: "33", OperatingUnit: "1000", ProgramCode: "D", BudgetedAmount: 5000},
+		{Year: 2024, Month: 9, Division: "33", OperatingUnit: "1000", ProgramCode: "D", BudgetedAmount: -5000},
+	}, programSummary)
+	createImport(t, db, "fsf.operating-unit-program-summary", 2024, 10, []databasemodel.FSFOperatingUnitProgramSummary{
+		{Year: 2024, Month: 10, Division: "33", OperatingUnit: "1000", ProgramCode: "A", BudgetedAmount: 999999, ExpendedAmount: 999999},
+	}, programSummary)
+
+	expenditureSummary := func(record *databasemodel.FSFOperatingUnitExpenditureSummary, importID uint) {
+		record.ImportID = importID
+	}
+	for month, expended := range map[int]databasemodel.Money{7: 10000, 8: 25000, 9: 30000, 10: 999999} {
+		createImport(t, db, "fsf.operating-unit-expenditure-summary", 2024, month, []databasemodel.FSFOperatingUnitExpenditureSummary{
+			{Year: 2024, Month: month, Division: "33", OperatingUnit: "1000", BudgetedAmount: 100000, ExpendedAmount: expended},
+			{Year: 2024, Month: month, Division: "33", OperatingUnit: "2000", BudgetedAmount: 50000, ExpendedAmount: expended / 10},
+		}, expenditureSummary)
+	}
+}
+
+func TestSectionQueries(t *testing.T) {
+	db := openTestDatabase(t)
+	createFixture(t, db)
+
+	fsys, err := newTemplateFS("")
+	if err != nil {
+		t.Fatalf("Could not open the templates: %v", err)
+	}
+	definition, err := loadReportDefinition(fsys)
+	if err != nil {
+		t.Fatalf("Could not load the report definition: %v", err)
+	}
+	for _, section := range definition.Sections {
+		for name, filename := range section.Queries {
+			t.Run(section.Name+"/"+name, func(t *testing.T) {
+				rows := runSectionQuery(t, db, filename, testParameters())
+				if len(rows) == 0 {
+					t.Fatalf("Expected some rows from %s.", filename)
+				}
+				// Money stays in cents until it is shown.
+				for column, value := range rows[0] {
+					if !definition.isMoneyColumn(column) || value == nil {
+						continue
+					}
+					if _, ok := value.(int64); !ok {
+						t.Errorf("Expected %s to be whole cents; got %#v.", column, value)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestProjection(t *testing.T) {
+	db := openTestDatabase(t)
+	createFixture(t, db)
+
+	expected := map[string]struct {
+		projected int64
+		overspend int64
+	}{
+		// The expended amount over the elapsed part of the year.
+		"A": {projected: 120000, overspend: 1},
+		// The expended and encumbered amounts, since that is more.
+		"B": {projected: 60000, overspend: 0},
+	}
+	rows := runSectionQuery(t, db, "projection.sql", testParameters())
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected only the programs with a budget; got %v.", rows)
+	}
+	for _, row := range rows {
+		program := row["program_code"].(string)
+		e, ok := expected[program]
+		if !ok {
+			t.Errorf("Unexpected program %q.", program)
+			continue
+		}
+		if row["projected_amount"] != e.projected {
+			t.Errorf("%s: expected a projection of %d; got %#v.", program, e.projected, row["projected_amount"])
+		}
+		if row["projected_overspend_amount"] != e.projected-100000 {
+			t.Errorf("%s: expected an overspend of %d; got %#v.", program, e.projected-100000, row["projected_overspend_amount"])
+		}
+		if toFloat(row["overspend"]) != float64(e.overspend) {
+			t.Errorf("%s: expected overspend to be %d; got %#v.", program, e.overspend, row["overspend"])
+		}
+	}
+}
+
+func TestTrends(t *testing.T) {
+	db := openTestDatabase(t)
+	createFixture(t, db)
+
+	rows := runSectionQuery(t, db, "trends-units.sql", testParameters())
+	expected := []struct {
+		operatingUnit  string
+		month          int64
+		hasPrevious    bool
+		expendedChange any
+	}{
+		// Each operating unit is compared only against its own previous month, and the month after the target is left out.
+		{operatingUnit: "1000", month: 7, hasPrevious: false, expendedChange: nil},
+		{operatingUnit: "1000", month: 8, hasPrevious: true, expendedChange: int64(15000)},
+		{operatingUnit: "1000", month: 9, hasPrevious: true, expendedChange: int64(5000)},
+		{operatingUnit: "2000", month: 7, hasPrevious: false, expendedChange: nil},
+		{operatingUnit: "2000", month: 8, hasPrevious: true, expendedChange: int64(1500)},
+		{operatingUnit: "2000", month: 9, hasPrevious: true, expendedChange: int64(500)},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows; got %v.", len(expected), rows)
+	}
+	for i, e := range expected {
+		row := rows[i]
+		if row["operating_unit"] != e.operatingUnit || row["period_month"] != e.month {
+			t.Errorf("Row %d: expected %s for month %d; got %v.", i, e.operatingUnit, e.month, row)
+			continue
+		}
+		if (toFloat(row["has_previous"]) != 0) != e.hasPrevious {
+			t.Errorf("%s %d: expected has_previous to be %t; got %#v.", e.operatingUnit, e.month, e.hasPrevious, row["has_previous"])
+		}
+		if row["expended_change"] != e.expendedChange {
+			t.Errorf("%s %d: expected an expended change of %#v; got %#v.", e.operatingUnit, e.month, e.expendedChange, row["expended_change"])
+		}
+	}
+
+	rows = runSectionQuery(t, db, "trends-divisions.sql", testParameters())
+	if len(rows) != 3 || rows[2]["expended_change"] != int64(5500) {
+		t.Errorf("Expected the division to change by the sum of its operating units; got %v.", rows)
+	}
+}
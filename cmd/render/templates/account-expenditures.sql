@@ -0,0 +1,24 @@
+-- The accounts with the most spending come first, since those are the ones that people ask about.
+SELECT
+	report.division,
+	MAX(report.department_description) AS department_description,
+	report.fiscal_year,
+	report.account_period,
+	report.account,
+	MAX(report.account_description) AS account_description,
+	SUM(report.local_funds_month_to_date) AS local_funds_month_to_date,
+	SUM(report.state_funds_month_to_date) AS state_funds_month_to_date,
+	SUM(report.total_funds_month_to_date) AS total_funds_month_to_date,
+	SUM(report.local_funds_year_to_date) AS local_funds_year_to_date,
+	SUM(report.state_funds_year_to_date) AS state_funds_year_to_date,
+	SUM(report.total_funds_year_to_date) AS total_funds_year_to_date
+FROM
+	mobius_dgl115 AS report
+	INNER JOIN imports
+		ON imports.id = report.import_id
+WHERE
+	imports.period_year = @target_year AND imports.period_month = @target_month
+GROUP BY
+	report.division, report.fiscal_year, report.account_period, report.account
+ORDER BY
+	report.division, report.fiscal_year, report.account_period, total_funds_year_to_date DESC, report.account
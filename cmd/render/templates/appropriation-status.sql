@@ -0,0 +1,39 @@
+SELECT
+	report.division,
+	department.department_description,
+	report.as_of_date,
+	report.fund,
+	report.appropriation,
+	report.appropriation_type,
+	report.appropriation_description,
+	report.end_date,
+	SUM(report.available_amount) AS available_amount,
+	SUM(report.encumbered_amount) AS encumbered_amount,
+	SUM(report.current_year_expenses) + SUM(report.prior_year_expenses) AS expended_amount,
+	SUM(report.remaining_spend_authorized) AS remaining_amount,
+	-- Only money that has not been spent yet can lapse.
+	CASE
+		WHEN
+			report.end_date >= report.as_of_date
+			AND julianday(report.end_date) - julianday(report.as_of_date) <= @expiring_within_days
+			AND SUM(report.remaining_spend_authorized) > 0
+		THEN 1
+		ELSE 0
+	END AS expiring
+FROM
+	mobius_dgl060 AS report
+	INNER JOIN imports
+		ON imports.id = report.import_id
+	INNER JOIN
+	(
+		SELECT DISTINCT division, department_description FROM mobius_dgl115
+	) AS department
+		ON report.division = department.division
+WHERE
+	imports.period_year = @target_year AND imports.period_month = @target_month
+GROUP BY
+	report.division, report.fund, report.appropriation_type, report.appropriation, report.as_of_date, report.end_date
+HAVING
+	SUM(report.available_amount) <> 0
+ORDER BY
+	report.division, report.fund, report.appropriation_type, report.appropriation
@@ -0,0 +1,13 @@
+SELECT
+	printf('%04d-%02d', report.period_year, report.period_month) AS period,
+	SUM(budget_amount) AS budget_amount,
+	SUM(encumbered_amount) + SUM(expended_amount) AS used_amount,
+	SUM(budget_amount) - SUM(encumbered_amount) - SUM(expended_amount) AS available_amount
+FROM
+	fsf_operating_unit_expenditure_summaries AS report
+WHERE
+	report.period_year * 100 + report.period_month BETWEEN @fiscal_start_year * 100 + @fiscal_start_month AND @target_year * 100 + @target_month
+GROUP BY
+	report.period_year, report.period_month
+ORDER BY
+	report.period_year, report.period_month
@@ -0,0 +1,23 @@
+SELECT
+	report.division,
+	department.department_description,
+	operating_unit,
+	operating_unit_description,
+	program_code,
+	program_code_description,
+	SUM(budget_amount) AS budget_amount,
+	SUM(encumbered_amount) AS encumbered_amount,
+	SUM(expended_amount) AS expended_amount
+FROM
+	fsf_operating_unit_program_summaries AS report
+	INNER JOIN
+	(
+		SELECT DISTINCT division, department_description FROM mobius_dgl115
+	) AS department
+		ON report.division = department.division
+WHERE
+	report.period_year = @target_year AND report.period_month = @target_month
+GROUP BY
+	report.division, operating_unit, program_code
+HAVING
+	SUM(budget_amount) > 0
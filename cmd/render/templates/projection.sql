@@ -0,0 +1,49 @@
+-- Spending is projected to the end of the fiscal year at the current rate.
+-- Encumbrances are money that has already been committed, so a line will spend at least its expended and encumbered amounts.
+SELECT
+	division,
+	department_description,
+	operating_unit,
+	operating_unit_description,
+	program_code,
+	program_code_description,
+	budget_amount,
+	encumbered_amount,
+	expended_amount,
+	CAST(ROUND(projected_amount) AS INTEGER) AS projected_amount,
+	CAST(ROUND(projected_amount) AS INTEGER) - budget_amount AS projected_overspend_amount,
+	ROUND(projected_amount) > budget_amount AS overspend
+FROM
+	(
+		SELECT
+			*,
+			MAX(expended_amount / @fiscal_year_elapsed, expended_amount + encumbered_amount) AS projected_amount
+		FROM
+			(
+				SELECT
+					report.division,
+					department.department_description,
+					operating_unit,
+					operating_unit_description,
+					program_code,
+					program_code_description,
+					SUM(budget_amount) AS budget_amount,
+					SUM(encumbered_amount) AS encumbered_amount,
+					SUM(expended_amount) AS expended_amount
+				FROM
+					fsf_operating_unit_program_summaries AS report
+					INNER JOIN
+					(
+						SELECT DISTINCT division, department_description FROM mobius_dgl115
+					) AS department
+						ON report.division = department.division
+				WHERE
+					report.period_year = @target_year AND report.period_month = @target_month
+				GROUP BY
+					report.division, operating_unit, program_code
+				HAVING
+					SUM(budget_amount) > 0
+			)
+	)
+ORDER BY
+	division, operating_unit, program_code
@@ -0,0 +1,194 @@
+-- Each comparison lines up one figure from FSF (the Data Service Center) against the same figure from Mobius (the state ERP).
+-- A side is missing when it has no rows at all for that division (and fund), which is different from having a total of zero.
+WITH
+fsf_divisions AS (
+	SELECT
+		division,
+		SUM(encumbered_amount) AS encumbered_amount,
+		SUM(expended_amount) AS expended_amount
+	FROM
+		fsf_operating_unit_expenditure_summaries
+	WHERE
+		period_year = @target_year AND period_month = @target_month
+	GROUP BY
+		division
+),
+-- The detailed activity report is the only FSF report that has the fund.
+-- It is downloaded one month at a time, so the months of the fiscal year so far are added up to match DGL060's year-to-date expenses.
+fsf_funds AS (
+	SELECT
+		report.division,
+		report.fund,
+		SUM(report.amount) AS expended_amount
+	FROM
+		fsf_detailed_activities AS report
+		INNER JOIN imports
+			ON imports.id = report.import_id
+	WHERE
+		imports.period_year * 100 + imports.period_month BETWEEN @fiscal_start_year * 100 + @fiscal_start_month AND @target_year * 100 + @target_month
+	GROUP BY
+		report.division, report.fund
+),
+-- A month that was not imported would look like a large discrepancy, so the comparison is only made once every month of the
+-- fiscal year so far is there.
+fsf_fund_months AS (
+	SELECT
+		COUNT(DISTINCT imports.period_year * 100 + imports.period_month) AS imported_months
+	FROM
+		imports
+	WHERE
+		imports.report_type = 'fsf.detailed-activity-report'
+		AND imports.period_year * 100 + imports.period_month BETWEEN @fiscal_start_year * 100 + @fiscal_start_month AND @target_year * 100 + @target_month
+),
+dgl060_funds AS (
+	SELECT
+		report.division,
+		report.fund,
+		SUM(report.encumbered_amount) AS encumbered_amount,
+		SUM(report.current_year_expenses) AS expended_amount
+	FROM
+		mobius_dgl060 AS report
+		INNER JOIN imports
+			ON imports.id = report.import_id
+	WHERE
+		imports.period_year = @target_year AND imports.period_month = @target_month
+	GROUP BY
+		report.division, report.fund
+),
+dgl060_divisions AS (
+	SELECT
+		division,
+		SUM(encumbered_amount) AS encumbered_amount,
+		SUM(expended_amount) AS expended_amount
+	FROM
+		dgl060_funds
+	GROUP BY
+		division
+),
+dgl115_divisions AS (
+	SELECT
+		report.division,
+		SUM(report.total_funds_year_to_date) AS expended_amount
+	FROM
+		mobius_dgl115 AS report
+		INNER JOIN imports
+			ON imports.id = report.import_id
+	WHERE
+		imports.period_year = @target_year AND imports.period_month = @target_month
+	GROUP BY
+		report.division
+),
+divisions AS (
+	SELECT division FROM fsf_divisions
+	UNION
+	SELECT division FROM dgl060_divisions
+	UNION
+	SELECT division FROM dgl115_divisions
+),
+funds AS (
+	SELECT division, fund FROM fsf_funds
+	UNION
+	SELECT division, fund FROM dgl060_funds
+),
+comparisons AS (
+	SELECT
+		divisions.division,
+		'' AS fund,
+		'Expended' AS measure,
+		'FSF Expenditure Summary' AS fsf_source,
+		fsf.expended_amount AS fsf_amount,
+		'DGL060' AS mobius_source,
+		mobius.expended_amount AS mobius_amount,
+		NULL AS fsf_months
+	FROM
+		divisions
+		LEFT JOIN fsf_divisions AS fsf
+			ON fsf.division = divisions.division
+		LEFT JOIN dgl060_divisions AS mobius
+			ON mobius.division = divisions.division
+	UNION ALL
+	SELECT
+		divisions.division,
+		'' AS fund,
+		'Expended' AS measure,
+		'FSF Expenditure Summary' AS fsf_source,
+		fsf.expended_amount AS fsf_amount,
+		'DGL115' AS mobius_source,
+		mobius.expended_amount AS mobius_amount,
+		NULL AS fsf_months
+	FROM
+		divisions
+		LEFT JOIN fsf_divisions AS fsf
+			ON fsf.division = divisions.division
+		LEFT JOIN dgl115_divisions AS mobius
+			ON mobius.division = divisions.division
+	UNION ALL
+	SELECT
+		divisions.division,
+		'' AS fund,
+		'Encumbered' AS measure,
+		'FSF Expenditure Summary' AS fsf_source,
+		fsf.encumbered_amount AS fsf_amount,
+		'DGL060' AS mobius_source,
+		mobius.encumbered_amount AS mobius_amount,
+		NULL AS fsf_months
+	FROM
+		divisions
+		LEFT JOIN fsf_divisions AS fsf
+			ON fsf.division = divisions.division
+		LEFT JOIN dgl060_divisions AS mobius
+			ON mobius.division = divisions.division
+	UNION ALL
+	SELECT
+		funds.division,
+		funds.fund,
+		'Expended' AS measure,
+		'FSF Detailed Activity (year to date)' AS fsf_source,
+		fsf.expended_amount AS fsf_amount,
+		'DGL060' AS mobius_source,
+		mobius.expended_amount AS mobius_amount,
+		fsf_fund_months.imported_months AS fsf_months
+	FROM
+		funds
+		CROSS JOIN fsf_fund_months
+		LEFT JOIN fsf_funds AS fsf
+			ON fsf.division = funds.division AND fsf.fund = funds.fund
+		LEFT JOIN dgl060_funds AS mobius
+			ON mobius.division = funds.division AND mobius.fund = funds.fund
+)
+SELECT
+	comparisons.division,
+	department.department_description,
+	comparisons.fund,
+	comparisons.measure,
+	comparisons.fsf_source,
+	comparisons.fsf_amount,
+	comparisons.mobius_source,
+	comparisons.mobius_amount,
+	COALESCE(comparisons.fsf_amount, 0) - COALESCE(comparisons.mobius_amount, 0) AS difference_amount,
+	CASE
+		WHEN comparisons.fsf_amount IS NULL THEN 'FSF'
+		WHEN comparisons.mobius_amount IS NULL THEN 'Mobius'
+		ELSE ''
+	END AS missing,
+	comparisons.fsf_months,
+	@fiscal_months_elapsed AS expected_months,
+	CASE
+		WHEN comparisons.fsf_months < @fiscal_months_elapsed THEN 1
+		ELSE 0
+	END AS incomplete,
+	CASE
+		WHEN comparisons.fsf_months < @fiscal_months_elapsed THEN 0
+		WHEN comparisons.fsf_amount IS NULL OR comparisons.mobius_amount IS NULL THEN 1
+		WHEN ABS(comparisons.fsf_amount - comparisons.mobius_amount) > @reconciliation_threshold THEN 1
+		ELSE 0
+	END AS discrepancy
+FROM
+	comparisons
+	LEFT JOIN
+	(
+		SELECT DISTINCT division, department_description FROM mobius_dgl115
+	) AS department
+		ON comparisons.division = department.division
+ORDER BY
+	comparisons.division, comparisons.fund, comparisons.measure, comparisons.mobius_source
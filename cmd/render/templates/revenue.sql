@@ -0,0 +1,41 @@
+SELECT
+	report.division,
+	department.department_description,
+	report.revenue_account,
+	report.revenue_account_description,
+	SUM(report.local_funds_current) AS local_funds_current,
+	SUM(report.local_funds_year_to_date) AS local_funds_year_to_date,
+	SUM(report.state_funds_current) AS state_funds_current,
+	SUM(report.state_funds_year_to_date) AS state_funds_year_to_date,
+	-- Compare the revenue against what the FSF reports say has been spent.
+	COALESCE(MAX(expenditure.encumbered_amount), 0) AS division_encumbered_amount,
+	COALESCE(MAX(expenditure.expended_amount), 0) AS division_expended_amount
+FROM
+	mobius_dgl114 AS report
+	INNER JOIN imports
+		ON imports.id = report.import_id
+	INNER JOIN
+	(
+		SELECT DISTINCT division, department_description FROM mobius_dgl115
+	) AS department
+		ON report.division = department.division
+	LEFT JOIN
+	(
+		SELECT
+			division,
+			SUM(encumbered_amount) AS encumbered_amount,
+			SUM(expended_amount) AS expended_amount
+		FROM
+			fsf_operating_unit_expenditure_summaries
+		WHERE
+			period_year = @target_year AND period_month = @target_month
+		GROUP BY
+			division
+	) AS expenditure
+		ON report.division = expenditure.division
+WHERE
+	imports.period_year = @target_year AND imports.period_month = @target_month
+GROUP BY
+	report.division, report.revenue_account
+ORDER BY
+	report.division, report.revenue_account
@@ -0,0 +1,38 @@
+SELECT
+	division,
+	department_description,
+	period_year,
+	period_month,
+	budget_amount,
+	encumbered_amount,
+	expended_amount,
+	LAG(budget_amount) OVER period_window IS NOT NULL AS has_previous,
+	budget_amount - LAG(budget_amount) OVER period_window AS budget_change,
+	encumbered_amount - LAG(encumbered_amount) OVER period_window AS encumbered_change,
+	expended_amount - LAG(expended_amount) OVER period_window AS expended_change
+FROM
+	(
+		SELECT
+			report.division,
+			department.department_description,
+			report.period_year,
+			report.period_month,
+			SUM(budget_amount) AS budget_amount,
+			SUM(encumbered_amount) AS encumbered_amount,
+			SUM(expended_amount) AS expended_amount
+		FROM
+			fsf_operating_unit_expenditure_summaries AS report
+			INNER JOIN
+			(
+				SELECT DISTINCT division, department_description FROM mobius_dgl115
+			) AS department
+				ON report.division = department.division
+		WHERE
+			report.period_year * 100 + report.period_month <= @target_year * 100 + @target_month
+		GROUP BY
+			report.division, report.period_year, report.period_month
+	)
+WINDOW
+	period_window AS (PARTITION BY division ORDER BY period_year, period_month)
+ORDER BY
+	division, period_year, period_month
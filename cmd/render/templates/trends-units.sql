@@ -0,0 +1,35 @@
+SELECT
+	division,
+	operating_unit,
+	operating_unit_description,
+	period_year,
+	period_month,
+	budget_amount,
+	encumbered_amount,
+	expended_amount,
+	LAG(budget_amount) OVER period_window IS NOT NULL AS has_previous,
+	budget_amount - LAG(budget_amount) OVER period_window AS budget_change,
+	encumbered_amount - LAG(encumbered_amount) OVER period_window AS encumbered_change,
+	expended_amount - LAG(expended_amount) OVER period_window AS expended_change
+FROM
+	(
+		SELECT
+			division,
+			operating_unit,
+			MAX(operating_unit_description) AS operating_unit_description,
+			period_year,
+			period_month,
+			SUM(budget_amount) AS budget_amount,
+			SUM(encumbered_amount) AS encumbered_amount,
+			SUM(expended_amount) AS expended_amount
+		FROM
+			fsf_operating_unit_expenditure_summaries
+		WHERE
+			period_year * 100 + period_month <= @target_year * 100 + @target_month
+		GROUP BY
+			division, operating_unit, period_year, period_month
+	)
+WINDOW
+	period_window AS (PARTITION BY division, operating_unit ORDER BY period_year, period_month)
+ORDER BY
+	division, operating_unit, period_year, period_month
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// xlsxCurrencyFormat is the number format used for every money cell.
+const xlsxCurrencyFormat = `"$"#,##0.00;[Red]-"$"#,##0.00`
+
+// xlsxWorkbook wraps the workbook with the styles that every sheet uses.
+type xlsxWorkbook struct {
+	file          *excelize.File
+	headerStyle   int
+	currencyStyle int
+	dateStyle     int
+	sheetNames    map[string]bool
+}
+
+// renderXLSX builds a workbook with one sheet per report section, followed by the raw contents of every table.
+func renderXLSX(db *gorm.DB, fsys fs.FS, definition *ReportDefinition, parameters map[string]any) ([]byte, error) {
+	workbook := &xlsxWorkbook{
+		file: excelize.NewFile(),
+		// A new workbook always starts with an empty "Sheet1", which is deleted at the end; a section with the same
+		// name would otherwise be written into it and deleted along with it.
+		sheetNames: map[string]bool{"sheet1": true},
+	}
+	defer workbook.file.Close()
+
+	var err error
+	workbook.headerStyle, err = workbook.file.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"#F0F0F0"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create header style: %w", err)
+	}
+	currencyFormat := xlsxCurrencyFormat
+	workbook.currencyStyle, err = workbook.file.NewStyle(&excelize.Style{
+		CustomNumFmt: &currencyFormat,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create currency style: %w", err)
+	}
+	workbook.dateStyle, err = workbook.file.NewStyle(&excelize.Style{
+		NumFmt: 14, // This is the built-in short date format.
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create date style: %w", err)
+	}
+
+	// The sheets for the sections come first, since they are what most people are looking for.
+	for _, section := range definition.Sections {
+		fmt.Printf("Exporting section: %s\n", section.Name)
+
+		queryNames := slices.Sorted(maps.Keys(section.Queries))
+		for _, queryName := range queryNames {
+			contents, err := fs.ReadFile(fsys, section.Queries[queryName])
+			if err != nil {
+				return nil, fmt.Errorf("section %q: could not read query %q: %w", section.Name, section.Queries[queryName], err)
+			}
+			columns, rows, err := queryRows(db, string(contents), parameters)
+			if err != nil {
+				return nil, fmt.Errorf("section %q: could not run query %q: %w", section.Name, section.Queries[queryName], err)
+			}
+
+			sheetName := section.Title
+			if len(queryNames) > 1 {
+				sheetName += " - " + queryName
+			}
+			err = workbook.addSheet(sheetName, columns, rows, definition.isMoneyColumn)
+			if err != nil {
+				return nil, fmt.Errorf("section %q: %w", section.Name, err)
+			}
+		}
+	}
+
+	for _, model := range databasemodel.Models() {
+		statement := &gorm.Statement{DB: db}
+		err := statement.Parse(model)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse model: %w", err)
+		}
+		table := statement.Schema.Table
+		fmt.Printf("Exporting table: %s\n", table)
+
+		var selects []string
+		moneyColumns := map[string]bool{}
+		for _, field := range statement.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			selects = append(selects, field.DBName)
+			if field.FieldType == reflect.TypeOf(databasemodel.Money(0)) {
+				moneyColumns[field.DBName] = true
+			}
+		}
+		columns, rows, err := queryRows(db, "SELECT "+strings.Join(selects, ", ")+" FROM "+table, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not read table %q: %w", table, err)
+		}
+		err = workbook.addSheet(table, columns, rows, func(column string) bool { return moneyColumns[column] })
+		if err != nil {
+			return nil, fmt.Errorf("table %q: %w", table, err)
+		}
+	}
+
+	// A new workbook always starts with an empty "Sheet1".
+	err = workbook.file.DeleteSheet("Sheet1")
+	if err != nil {
+		return nil, fmt.Errorf("could not delete the default sheet: %w", err)
+	}
+	workbook.file.SetActiveSheet(0)
+
+	buffer, err := workbook.file.WriteToBuffer()
+	if err != nil {
+		return nil, fmt.Errorf("could not write workbook: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// addSheet adds a sheet with a frozen header row and one row per result.
+//
+// The columns that hold money get the currency format.
+func (w *xlsxWorkbook) addSheet(name string, columns []string, rows []Row, isMoney func(column string) bool) error {
+	name = w.uniqueSheetName(name)
+
+	_, err := w.file.NewSheet(name)
+	if err != nil {
+		return fmt.Errorf("could not create sheet %q: %w", name, err)
+	}
+	streamWriter, err := w.file.NewStreamWriter(name)
+	if err != nil {
+		return fmt.Errorf("could not create sheet %q: %w", name, err)
+	}
+
+	if len(columns) > 0 {
+		err = streamWriter.SetColWidth(1, len(columns), 18)
+		if err != nil {
+			return fmt.Errorf("could not set column widths: %w", err)
+		}
+	}
+	err = streamWriter.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+	if err != nil {
+		return fmt.Errorf("could not freeze the header row: %w", err)
+	}
+
+	header := make([]any, len(columns))
+	for c, column := range columns {
+		header[c] = excelize.Cell{StyleID: w.headerStyle, Value: column}
+	}
+	err = streamWriter.SetRow("A1", header)
+	if err != nil {
+		return fmt.Errorf("could not write header: %w", err)
+	}
+
+	money := make([]bool, len(columns))
+	for c, column := range columns {
+		money[c] = isMoney(column)
+	}
+	for r, row := range rows {
+		values := make([]any, len(columns))
+		for c, column := range columns {
+			values[c] = w.cell(row[column], money[c])
+		}
+		cellName, err := excelize.CoordinatesToCellName(1, r+2)
+		if err != nil {
+			return err
+		}
+		err = streamWriter.SetRow(cellName, values)
+		if err != nil {
+			return fmt.Errorf("could not write row %d: %w", r+1, err)
+		}
+	}
+
+	err = streamWriter.Flush()
+	if err != nil {
+		return fmt.Errorf("could not write sheet %q: %w", name, err)
+	}
+	return nil
+}
+
+// cell converts a database value into a spreadsheet cell.
+//
+// Money is stored in cents, but the sheet shows it as dollars with the currency format; other numbers, such as
+// ratios, are left as they are.
+func (w *xlsxWorkbook) cell(value any, money bool) any {
+	switch v := value.(type) {
+	case float64, int64:
+		if money {
+			return excelize.Cell{StyleID: w.currencyStyle, Value: toMoney(v).Float64()}
+		}
+		return v
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		return excelize.Cell{StyleID: w.dateStyle, Value: v}
+	case []byte:
+		return string(v)
+	default:
+		return v
+	}
+}
+
+// uniqueSheetName turns the name into something that Excel allows, and makes sure that it has not been used yet.
+//
+// Sheet names are limited to 31 characters, cannot contain some punctuation, and cannot start or end with an
+// apostrophe.  Names are compared without regard to case, and "History" is reserved by Excel.
+func (w *xlsxWorkbook) uniqueSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(strings.Trim(name, "'"))
+	if name == "" {
+		name = "Sheet"
+	}
+
+	const maxLength = 31
+	candidate := truncateRunes(name, maxLength)
+	for i := 2; w.sheetNames[strings.ToLower(candidate)] || strings.EqualFold(candidate, "History"); i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = truncateRunes(name, maxLength-len(suffix)) + suffix
+	}
+	w.sheetNames[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// truncateRunes returns at most the first n characters of the string.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return strings.TrimRight(string(runes[:n]), " '")
+}
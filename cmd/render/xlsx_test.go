@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestUniqueSheetName(t *testing.T) {
+	w := &xlsxWorkbook{sheetNames: map[string]bool{"sheet1": true}}
+
+	names := []struct {
+		input  string
+		output string
+	}{
+		{input: "Revenue", output: "Revenue"},
+		{input: "revenue", output: "revenue (2)"},
+		{input: "Revenue", output: "Revenue (3)"},
+		{input: "A/B: [Summary]?", output: "A_B_ _Summary__"},
+		{input: "Sheet1", output: "Sheet1 (2)"},
+		{input: "history", output: "history (2)"},
+		{input: "'Quoted'", output: "Quoted"},
+		{input: "", output: "Sheet"},
+		{input: "Expenditures by Operating Unit and Program", output: "Expenditures by Operating Unit"},
+		{input: "Expenditures by Operating Unit and Fund", output: "Expenditures by Operating U (2)"},
+		{input: strings.Repeat("é", 40), output: strings.Repeat("é", 31)},
+	}
+	for _, name := range names {
+		output := w.uniqueSheetName(name.input)
+		if output != name.output {
+			t.Errorf("uniqueSheetName(%q) = %q; expected %q", name.input, output, name.output)
+		}
+		if count := utf8.RuneCountInString(output); count > 31 {
+			t.Errorf("uniqueSheetName(%q) is %d characters", name.input, count)
+		}
+	}
+}
+
+func TestMoneyColumns(t *testing.T) {
+	fsys, err := newTemplateFS("")
+	if err != nil {
+		t.Fatalf("Could not open the templates: %v", err)
+	}
+	definition, err := loadReportDefinition(fsys)
+	if err != nil {
+		t.Fatalf("Could not load the report definition: %v", err)
+	}
+
+	columns := map[string]bool{
+		"budget_amount":              true,
+		"projected_overspend_amount": true,
+		"expended_change":            true,
+		"local_funds_year_to_date":   true,
+		"total_funds_month_to_date":  true,
+		"division":                   false,
+		"fiscal_year_elapsed":        false,
+		"has_previous":               false,
+		"overspend":                  false,
+		"fsf_months":                 false,
+	}
+	for column, money := range columns {
+		if definition.isMoneyColumn(column) != money {
+			t.Errorf("%s: expected money to be %v.", column, money)
+		}
+	}
+}
+
+func TestCell(t *testing.T) {
+	w := &xlsxWorkbook{currencyStyle: 7}
+
+	if cell, ok := w.cell(int64(123450), true).(excelize.Cell); !ok || cell.StyleID != w.currencyStyle || cell.Value != 1234.5 {
+		t.Errorf("Expected money to be dollars with the currency style; got %#v.", w.cell(int64(123450), true))
+	}
+	if value := w.cell(0.25, false); value != 0.25 {
+		t.Errorf("Expected a ratio to be left alone; got %#v.", value)
+	}
+	if value := w.cell(int64(3), false); value != int64(3) {
+		t.Errorf("Expected a count to be left alone; got %#v.", value)
+	}
+}
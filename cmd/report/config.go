@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ConfigFile is the contents of the file given by "-config".
+//
+// Each profile describes one district, so that the same tool can be used by any district's committee:
+//
+//	{
+//		"defaultProfile": "christina",
+//		"profiles": {
+//			"christina": {
+//				"district": "Christina",
+//				"divisions": ["33", "51", "56", "60"],
+//				"mobiusPath": ["Repositories", "First State Financials", "Reports"],
+//				"mobiusReports": ["DGL060", "DGL114", "DGL115"],
+//				"mobiusReportFilePattern": "95%s00"
+//			}
+//		}
+//	}
+type ConfigFile struct {
+	DefaultProfile string             `json:"defaultProfile"` // This is the profile to use when "-profile" is not given.
+	Profiles       map[string]Profile `json:"profiles"`
+}
+
+// Profile is everything about a district that the reports depend on.
+type Profile struct {
+	District                string   `json:"district"`                // This is the district to log in to the DSC as.
+	Divisions               []string `json:"divisions"`               // These are the FSF divisions to download.
+	MobiusPath              []string `json:"mobiusPath"`              // This is the folder in Mobius that has the reports.
+	MobiusReports           []string `json:"mobiusReports"`           // These are the Mobius reports to download.
+	MobiusReportFilePattern string   `json:"mobiusReportFilePattern"` // This turns a division into the name of its report file in Mobius; the "%s" is the division.
+}
+
+// defaultProfileName is the profile to use when there is no config file.
+const defaultProfileName = "christina"
+
+// defaultProfiles are the profiles that are built in.
+var defaultProfiles = map[string]Profile{
+	defaultProfileName: {
+		District:                "Christina",
+		Divisions:               []string{"33", "51", "56", "60"},
+		MobiusPath:              []string{"Repositories", "First State Financials", "Reports"},
+		MobiusReports:           []string{"DGL060", "DGL114", "DGL115"},
+		MobiusReportFilePattern: "95%s00",
+	},
+}
+
+// loadProfile returns the named profile from the config file.
+//
+// If there is no config file, then the built-in profiles are used.  If there is no name, then the default profile is used.
+func loadProfile(filename string, name string) (Profile, error) {
+	configFile := ConfigFile{
+		DefaultProfile: defaultProfileName,
+		Profiles:       defaultProfiles,
+	}
+	if filename != "" {
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			return Profile{}, err
+		}
+		configFile = ConfigFile{}
+		err = json.Unmarshal(contents, &configFile)
+		if err != nil {
+			return Profile{}, fmt.Errorf("could not parse %s: %w", filename, err)
+		}
+	}
+
+	if name == "" {
+		name = configFile.DefaultProfile
+	}
+	if name == "" && len(configFile.Profiles) == 1 {
+		for n := range configFile.Profiles {
+			name = n
+		}
+	}
+	profile, ok := configFile.Profiles[name]
+	if !ok {
+		var names []string
+		for n := range configFile.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("profile %q not found; the profiles are: %s", name, strings.Join(names, ", "))
+	}
+
+	err := profile.validate()
+	if err != nil {
+		return Profile{}, fmt.Errorf("profile %q: %w", name, err)
+	}
+	return profile, nil
+}
+
+// validate makes sure that the profile has everything that it needs.
+func (p Profile) validate() error {
+	if len(p.Divisions) == 0 {
+		return fmt.Errorf("no divisions")
+	}
+	if len(p.MobiusReports) > 0 {
+		if len(p.MobiusPath) == 0 {
+			return fmt.Errorf("no Mobius path")
+		}
+		if strings.Count(p.MobiusReportFilePattern, "%s") != 1 {
+			return fmt.Errorf("the Mobius report file pattern must have exactly one %%s: %q", p.MobiusReportFilePattern)
+		}
+	}
+	return nil
+}
+
+// mobiusReportFile returns the name of the Mobius report file for the division, such as "953300" for "33".
+func (p Profile) mobiusReportFile(division string) string {
+	return fmt.Sprintf(p.MobiusReportFilePattern, division)
+}
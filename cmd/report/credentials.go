@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// These are the names of the credentials.
+//
+// The same names are used by every source, such as "CBOC_DELAWARE_PASSWORD" in the environment.
+const (
+	credentialDelawareUsername = "delaware-username"
+	credentialDelawarePassword = "delaware-password"
+	credentialDSCUsername      = "dsc-username"
+	credentialDSCPassword      = "dsc-password"
+	credentialERPUsername      = "erp-username"
+	credentialERPPassword      = "erp-password"
+)
+
+// keyringService is the service that the credentials are saved under in the OS keyring.
+const keyringService = "cboc-tools"
+
+// CredentialSource is somewhere that the usernames and passwords can come from.
+type CredentialSource interface {
+	// Lookup returns the credential with the given name, or an empty string if this source does not have it.
+	Lookup(name string) (string, error)
+	// String returns the name of the source, for the log.
+	String() string
+}
+
+// newCredentialSources returns the sources with the given names, in order.
+//
+// The names are "env", "file", "keyring", and "prompt".
+func newCredentialSources(names string, credentialsFile string) ([]CredentialSource, error) {
+	var sources []CredentialSource
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "env":
+			sources = append(sources, envSource{})
+		case "file":
+			sources = append(sources, &fileSource{filename: credentialsFile})
+		case "keyring":
+			sources = append(sources, &keyringSource{})
+		case "prompt":
+			sources = append(sources, &promptSource{})
+		default:
+			return nil, fmt.Errorf("unknown credential source: %q", name)
+		}
+	}
+	return sources, nil
+}
+
+// defaultCredentialsFile returns where the credentials file is kept if "-credentials-file" is not given.
+func defaultCredentialsFile() string {
+	directory, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(directory, "cboc-tools", "credentials.json")
+}
+
+// envSource reads the credentials from environment variables, such as "CBOC_DELAWARE_PASSWORD".
+type envSource struct{}
+
+func (envSource) Lookup(name string) (string, error) {
+	return os.Getenv(envVariable(name)), nil
+}
+
+func (envSource) String() string {
+	return "environment"
+}
+
+// envVariable returns the environment variable for a credential.
+func envVariable(name string) string {
+	return "CBOC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// fileSource reads the credentials from a JSON file, such as:
+//
+//	{
+//		"delaware-username": "jdoe@example.com",
+//		"delaware-password": "..."
+//	}
+//
+// The file must not be readable by anyone but its owner.
+type fileSource struct {
+	filename    string
+	credentials map[string]string // This is nil until the file has been read.
+}
+
+func (s *fileSource) Lookup(name string) (string, error) {
+	if s.credentials == nil {
+		s.credentials = map[string]string{}
+		if s.filename == "" {
+			return "", nil
+		}
+		info, err := os.Stat(s.filename)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		// Windows does not have Unix permissions, so the file is left to its ACLs there.
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+			return "", fmt.Errorf("%s can be read by other users (its permissions are %v); run \"chmod 600 %s\"", s.filename, info.Mode().Perm(), s.filename)
+		}
+		contents, err := os.ReadFile(s.filename)
+		if err != nil {
+			return "", err
+		}
+		err = json.Unmarshal(contents, &s.credentials)
+		if err != nil {
+			return "", fmt.Errorf("could not parse %s: %w", s.filename, err)
+		}
+	}
+	return s.credentials[name], nil
+}
+
+func (s *fileSource) String() string {
+	return "file " + s.filename
+}
+
+// keyringSource reads the credentials from the OS keyring.
+//
+// On Linux, this is anything that speaks the Secret Service API (such as GNOME Keyring or KeePassXC), and a credential
+// can be saved with:
+//
+//	secret-tool store --label="cboc-tools delaware-password" service cboc-tools username delaware-password
+//
+// If there is no keyring to talk to, then this source is skipped.
+type keyringSource struct {
+	unavailable bool
+}
+
+func (s *keyringSource) Lookup(name string) (string, error) {
+	if s.unavailable {
+		return "", nil
+	}
+	value, err := keyring.Get(keyringService, name)
+	if err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return "", nil
+		}
+		fmt.Printf("The keyring is not available: %v\n", err)
+		s.unavailable = true
+		return "", nil
+	}
+	return value, nil
+}
+
+func (s *keyringSource) String() string {
+	return "keyring"
+}
+
+// promptSource asks for the credentials on the terminal.
+//
+// Passwords are not echoed.  A blank answer means that the credential is not needed.
+type promptSource struct {
+	reader *bufio.Reader
+}
+
+func (s *promptSource) Lookup(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("cannot prompt for %s: standard input is not a terminal", name)
+	}
+
+	fmt.Printf("%s (leave blank to skip): ", name)
+	if strings.HasSuffix(name, "-password") {
+		value, err := term.ReadPassword(fd)
+		fmt.Printf("\n")
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(value)), nil
+	}
+	if s.reader == nil {
+		s.reader = bufio.NewReader(os.Stdin)
+	}
+	value, err := s.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func (s *promptSource) String() string {
+	return "prompt"
+}
+
+// lookupCredential returns the first value for the credential from the sources.
+//
+// A username given as a flag always wins, since it was asked for explicitly.  A password given as a flag is only
+// used if none of the sources have it.
+func lookupCredential(sources []CredentialSource, name string, value string) (string, error) {
+	if value != "" && strings.HasSuffix(name, "-username") {
+		return value, nil
+	}
+	for _, source := range sources {
+		// The prompt is only for what is still missing, so it is never asked about something that was given as a flag.
+		if _, ok := source.(*promptSource); ok && value != "" {
+			continue
+		}
+		v, err := source.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", source, err)
+		}
+		if v != "" {
+			fmt.Printf("Using %s from the %s.\n", name, source)
+			return v, nil
+		}
+	}
+	if value != "" && strings.HasSuffix(name, "-password") {
+		fmt.Printf("WARNING: -%s was given on the command line, where it can be seen in the shell history and by \"ps\"; use %s, the credentials file, or the keyring instead.\n", name, envVariable(name))
+	}
+	return value, nil
+}
+
+// loadCredentials fills in the usernames and passwords from the credential sources.
+func (c *Config) loadCredentials() error {
+	credentials := []struct {
+		name  string
+		value *string
+	}{
+		{credentialDelawareUsername, &c.DelawareUsername},
+		{credentialDelawarePassword, &c.DelawarePassword},
+		{credentialDSCUsername, &c.DSCUsername},
+		{credentialDSCPassword, &c.DSCPassword},
+		{credentialERPUsername, &c.ERPUsername},
+		{credentialERPPassword, &c.ERPPassword},
+	}
+	for _, credential := range credentials {
+		sources := c.CredentialSources
+		// The DSC credentials are usually the same as the Delaware ones, so there is no need to prompt for them.
+		if credential.name == credentialDSCUsername || credential.name == credentialDSCPassword {
+			sources = withoutPrompt(sources)
+		}
+		value, err := lookupCredential(sources, credential.name, *credential.value)
+		if err != nil {
+			return err
+		}
+		*credential.value = value
+	}
+	return nil
+}
+
+// withoutPrompt returns the sources other than the prompt.
+func withoutPrompt(sources []CredentialSource) []CredentialSource {
+	var output []CredentialSource
+	for _, source := range sources {
+		if _, ok := source.(*promptSource); ok {
+			continue
+		}
+		output = append(output, source)
+	}
+	return output
+}
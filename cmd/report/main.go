@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
-	"runtime/debug"
 	"strings"
 	"time"
 
@@ -27,6 +29,20 @@ type Config struct {
 	BaseDirectory    string
 	TargetYear       int
 	TargetMonth      int
+	Profile          Profile
+	Timeout          time.Duration // This is how long the whole run may take; zero means forever.
+	StepTimeout      time.Duration // This is how long any one step (such as logging in or downloading a report) may take; zero means forever.
+	Attempts         int           // This is how many times to try to download each report.
+	RetryDelay       time.Duration // This is how long to wait before the second attempt; the wait doubles after that.
+	MaxRetryDelay    time.Duration // This is the longest to wait between attempts.
+
+	CredentialSources []CredentialSource // These are where the usernames and passwords come from, in order; a username flag wins, but a password flag is only used if none of these have it.
+}
+
+// Period is saved alongside the downloaded reports so that they can be matched up with the period that they cover.
+type Period struct {
+	TargetYear  int `json:"targetYear"`
+	TargetMonth int `json:"targetMonth"`
 }
 
 func main() {
@@ -36,24 +52,58 @@ func main() {
 	var config Config
 	var sleepAfterSuccess time.Duration
 	var sleepAfterFailure time.Duration
+	var configFile string
+	var profileName string
+	var credentialSources string
+	var credentialsFile string
 	flag.BoolVar(&devTools, "dev-tools", false, "Show the dev tools.")
 	flag.BoolVar(&headless, "headless", true, "Set the headless mode.  If true, no browser will be shown.")
 	flag.DurationVar(&slowMotion, "slow-motion", 0, "Set the delay between actions.")
 	flag.StringVar(&config.BaseDirectory, "base-directory", "", "The location to save the results.")
-	flag.StringVar(&config.District, "district", "Christina", "The district.")
+	flag.StringVar(&configFile, "config", "", "The JSON file with the district profiles.  If not set, then the built-in profile for Christina is used.")
+	flag.StringVar(&profileName, "profile", "", "The district profile to use.  If not set, then the default profile from the config file is used.")
+	flag.StringVar(&config.District, "district", "", "The district.  If not set, then the district from the profile is used.")
+	flag.StringVar(&credentialSources, "credential-sources", "env,file", "Where to look for the usernames and passwords, in order: \"env\" (such as CBOC_DELAWARE_PASSWORD), \"file\", \"keyring\" (the OS keyring), and \"prompt\".")
+	flag.StringVar(&credentialsFile, "credentials-file", defaultCredentialsFile(), "The JSON file with the usernames and passwords.  It must only be readable by its owner.")
 	flag.StringVar(&config.DelawareUsername, "delaware-username", "", "The username.")
-	flag.StringVar(&config.DelawarePassword, "delaware-password", "", "The password.")
+	flag.StringVar(&config.DelawarePassword, "delaware-password", "", "The password.  This is only used if none of the credential sources have it.")
 	flag.StringVar(&config.DSCUsername, "dsc-username", "", "The username.")
-	flag.StringVar(&config.DSCPassword, "dsc-password", "", "The password.")
+	flag.StringVar(&config.DSCPassword, "dsc-password", "", "The password.  This is only used if none of the credential sources have it.")
 	flag.StringVar(&config.ERPUsername, "erp-username", "", "The username.")
-	flag.StringVar(&config.ERPPassword, "erp-password", "", "The password.")
+	flag.StringVar(&config.ERPPassword, "erp-password", "", "The password.  This is only used if none of the credential sources have it.")
 	flag.IntVar(&config.TargetYear, "target-year", 0, "The target year.")
 	flag.IntVar(&config.TargetMonth, "target-month", 0, "The target month.")
+	flag.DurationVar(&config.Timeout, "timeout", 2*time.Hour, "How long the whole run may take.  Zero means forever.")
+	flag.DurationVar(&config.StepTimeout, "step-timeout", 10*time.Minute, "How long any one step (such as logging in or downloading a report) may take.  Zero means forever.")
+	flag.IntVar(&config.Attempts, "attempts", 4, "How many times to try to download each report before giving up on it.")
+	flag.DurationVar(&config.RetryDelay, "retry-delay", 30*time.Second, "How long to wait before trying a report again.  This doubles after each attempt.")
+	flag.DurationVar(&config.MaxRetryDelay, "max-retry-delay", 5*time.Minute, "The longest to wait before trying a report again.")
 	flag.DurationVar(&sleepAfterSuccess, "sleep-after-success", 0, "How long to sleep at the end after success")
 	flag.DurationVar(&sleepAfterFailure, "sleep-after-failure", 5*time.Minute, "How long to sleep at the end after failure")
 
 	flag.Parse()
 
+	profile, err := loadProfile(configFile, profileName)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	config.Profile = profile
+	if config.District == "" {
+		config.District = profile.District
+	}
+
+	config.CredentialSources, err = newCredentialSources(credentialSources, credentialsFile)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	err = config.loadCredentials()
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
 	// The DSC username is the same as the Delaware username, but without the domain.
 	if config.DSCUsername == "" {
 		config.DSCUsername = config.DelawareUsername
@@ -87,21 +137,39 @@ func main() {
 
 	defer l.Cleanup()
 
-	controlURL := l.MustLaunch()
+	controlURL, err := l.Launch()
+	if err != nil {
+		fmt.Printf("Could not launch the browser: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Launch a new browser with default options, and connect to it.
 	browser := rod.New().
 		ControlURL(controlURL).
 		Trace(true).
-		SlowMotion(slowMotion).
-		MustConnect()
+		SlowMotion(slowMotion)
+	err = browser.Connect()
+	if err != nil {
+		fmt.Printf("Could not connect to the browser: %v\n", err)
+		os.Exit(1)
+	}
 
 	// Even you forget to close, rod will close it after main process ends.
-	defer browser.MustClose()
+	defer browser.Close()
+
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
 
-	err := doTheThing(browser, config)
+	err = doTheThing(ctx, browser, config)
 	if err != nil {
 		fmt.Printf("ERROR: %v\n", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			fmt.Printf("The step took longer than -step-timeout (%v), or the run took longer than -timeout (%v).\n", config.StepTimeout, config.Timeout)
+		}
 
 		fmt.Printf("Sleeping for %v...\n", sleepAfterFailure)
 		time.Sleep(sleepAfterFailure)
@@ -113,213 +181,253 @@ func main() {
 	time.Sleep(sleepAfterSuccess)
 }
 
-func doTheThing(browser *rod.Browser, config Config) error {
-	defer func() {
-		if r := recover(); r != nil {
-			fmt.Println("Recovered in f", r)
-			debug.PrintStack()
-			time.Sleep(10 * time.Minute)
-		}
-	}()
+// stepContext returns the context for one step, such as logging in or downloading a report.
+//
+// The step ends after the step timeout, or when the whole run does.
+func (c Config) stepContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.StepTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.StepTimeout)
+}
 
-	divisions := []string{"33", "51", "56", "60"}
+// doTheThing downloads every report that it can.
+//
+// A report that cannot be downloaded (even after retrying) does not stop the others; every failure is returned at the
+// end.
+func doTheThing(ctx context.Context, browser *rod.Browser, config Config) error {
+	divisions := config.Profile.Divisions
+	fmt.Printf("District: %s\n", config.District)
 	fmt.Printf("Divisions: %v\n", divisions)
 
-	if config.District != "" && config.DSCUsername != "" && config.DSCPassword != "" {
-		fmt.Printf("Doing: DSC\n")
-
-		dscInstance := dataservicecenter.New(browser)
-		err := dscInstance.Login(config.District, config.DSCUsername, config.DSCPassword)
+	// Record the period that these reports are for so that "parse-reports" can tag the rows with it.
+	{
+		fileName := config.BaseDirectory + string(filepath.Separator) + "period.json"
+		contents, err := json.MarshalIndent(Period{TargetYear: config.TargetYear, TargetMonth: config.TargetMonth}, "", "\t")
 		if err != nil {
 			return err
 		}
-
-		fsfInstance, err := dscInstance.FSF()
+		err = os.WriteFile(fileName, contents, 0644)
 		if err != nil {
 			return err
 		}
-		{
-			fileName := config.BaseDirectory + string(filepath.Separator) + "fsf.operating-unit-program-summary.csv"
-			if _, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-				contents, err := fsfInstance.DownloadOperatingUnitProgramSummaryReport(config.TargetYear, config.TargetMonth, false, "csv")
-				if err != nil {
-					return err
-				}
+	}
 
-				os.WriteFile(fileName, contents, 0644)
-			}
-		}
-		{
-			fileName := config.BaseDirectory + string(filepath.Separator) + "fsf.operating-unit-program-summary.pdf"
-			if _, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-				contents, err := fsfInstance.DownloadOperatingUnitProgramSummaryReport(config.TargetYear, config.TargetMonth, false, "pdf")
-				if err != nil {
-					return err
-				}
+	var failures Failures
 
-				os.WriteFile(fileName, contents, 0644)
-			}
-		}
-		{
-			fileName := config.BaseDirectory + string(filepath.Separator) + "fsf.operating-unit-expenditure-summary.csv"
-			if _, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-				contents, err := fsfInstance.DownloadOperatingUnitExpenditureSummaryReport(config.TargetYear, config.TargetMonth, divisions, "csv")
-				if err != nil {
-					return err
-				}
+	if config.District != "" && config.DSCUsername != "" && config.DSCPassword != "" {
+		fmt.Printf("Doing: DSC\n")
+		doDSC(ctx, browser, config, &failures)
+	}
 
-				os.WriteFile(fileName, contents, 0644)
-			}
-		}
-		{
-			fileName := config.BaseDirectory + string(filepath.Separator) + "fsf.operating-unit-expenditure-summary.pdf"
-			if _, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-				contents, err := fsfInstance.DownloadOperatingUnitExpenditureSummaryReport(config.TargetYear, config.TargetMonth, divisions, "pdf")
-				if err != nil {
-					return err
-				}
+	if config.DelawareUsername != "" && config.DelawarePassword != "" {
+		fmt.Printf("Doing: Delaware.gov\n")
+		doDelawareGov(ctx, browser, config, &failures)
+	}
 
-				os.WriteFile(fileName, contents, 0644)
-			}
-		}
-		{
-			fileName := config.BaseDirectory + string(filepath.Separator) + "fsf.detailed-activity-report.csv"
-			if _, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-				startDate := time.Date(config.TargetYear, time.Month(config.TargetMonth), 1, 12, 0, 0, 0, time.UTC)
-				endDate := startDate.AddDate(0, 1, 0).AddDate(0, 0, -1)
+	return failures.Err()
+}
 
-				contents, err := fsfInstance.DownloadDetailedActivityReport(startDate, endDate, divisions, "csv")
-				if err != nil {
-					return err
-				}
+// doDSC downloads the FSF reports from the Data Service Center.
+func doDSC(ctx context.Context, browser *rod.Browser, config Config, failures *Failures) {
+	divisions := config.Profile.Divisions
 
-				os.WriteFile(fileName, contents, 0644)
-			}
-		}
+	dscInstance := dataservicecenter.New(browser)
+	stepCtx, cancel := config.stepContext(ctx)
+	err := dscInstance.Login(stepCtx, config.District, config.DSCUsername, config.DSCPassword)
+	cancel()
+	if err != nil {
+		failures.Add("DSC login", err)
+		return
 	}
 
-	if config.DelawareUsername != "" && config.DelawarePassword != "" {
-		fmt.Printf("Doing: Delaware.gov\n")
+	stepCtx, cancel = config.stepContext(ctx)
+	fsfInstance, err := dscInstance.FSF(stepCtx)
+	cancel()
+	if err != nil {
+		failures.Add("FSF", err)
+		return
+	}
 
-		delawareGovInstance := delawaregov.New(browser)
-		err := delawareGovInstance.Login(config.DelawareUsername, config.DelawarePassword)
+	// Every download goes to the report's form first, so there is nothing else to do before trying again.
+	downloads := []struct {
+		fileName string
+		download func(ctx context.Context) ([]byte, error)
+	}{
+		{"fsf.operating-unit-program-summary.csv", func(ctx context.Context) ([]byte, error) {
+			return fsfInstance.DownloadOperatingUnitProgramSummaryReport(ctx, config.TargetYear, config.TargetMonth, false, "csv")
+		}},
+		{"fsf.operating-unit-program-summary.pdf", func(ctx context.Context) ([]byte, error) {
+			return fsfInstance.DownloadOperatingUnitProgramSummaryReport(ctx, config.TargetYear, config.TargetMonth, false, "pdf")
+		}},
+		{"fsf.operating-unit-expenditure-summary.csv", func(ctx context.Context) ([]byte, error) {
+			return fsfInstance.DownloadOperatingUnitExpenditureSummaryReport(ctx, config.TargetYear, config.TargetMonth, divisions, "csv")
+		}},
+		{"fsf.operating-unit-expenditure-summary.pdf", func(ctx context.Context) ([]byte, error) {
+			return fsfInstance.DownloadOperatingUnitExpenditureSummaryReport(ctx, config.TargetYear, config.TargetMonth, divisions, "pdf")
+		}},
+		{"fsf.detailed-activity-report.csv", func(ctx context.Context) ([]byte, error) {
+			startDate := time.Date(config.TargetYear, time.Month(config.TargetMonth), 1, 12, 0, 0, 0, time.UTC)
+			endDate := startDate.AddDate(0, 1, 0).AddDate(0, 0, -1)
+
+			return fsfInstance.DownloadDetailedActivityReport(ctx, startDate, endDate, divisions, "csv")
+		}},
+	}
+	for _, download := range downloads {
+		fileName := config.BaseDirectory + string(filepath.Separator) + download.fileName
+		err := config.downloadReport(ctx, fileName, func(ctx context.Context, attemptNumber int) ([]byte, error) {
+			return download.download(ctx)
+		})
 		if err != nil {
-			return err
+			failures.Add(download.fileName, err)
 		}
+	}
+}
 
-		if config.ERPUsername != "" && config.ERPPassword != "" {
-			fmt.Printf("Doing: ERP\n")
+// doDelawareGov logs in to Delaware.gov and downloads the Mobius reports from the ERP portal.
+func doDelawareGov(ctx context.Context, browser *rod.Browser, config Config, failures *Failures) {
+	divisions := config.Profile.Divisions
 
-			erpInstance, err := delawareGovInstance.ERP()
-			if err != nil {
-				return err
+	delawareGovInstance := delawaregov.New(browser)
+	stepCtx, cancel := config.stepContext(ctx)
+	err := delawareGovInstance.Login(stepCtx, config.DelawareUsername, config.DelawarePassword)
+	cancel()
+	if err != nil {
+		failures.Add("Delaware.gov login", err)
+		return
+	}
+
+	if config.ERPUsername == "" || config.ERPPassword == "" {
+		return
+	}
+
+	fmt.Printf("Doing: ERP\n")
+
+	stepCtx, cancel = config.stepContext(ctx)
+	erpInstance, err := delawareGovInstance.ERP(stepCtx)
+	cancel()
+	if err != nil {
+		failures.Add("ERP", err)
+		return
+	}
+
+	stepCtx, cancel = config.stepContext(ctx)
+	err = erpInstance.Login(stepCtx, config.ERPUsername, config.ERPPassword)
+	cancel()
+	if err != nil {
+		failures.Add("ERP login", err)
+		return
+	}
+
+	stepCtx, cancel = config.stepContext(ctx)
+	mobiusInstance, err := erpInstance.Mobius(stepCtx)
+	cancel()
+	if err != nil {
+		failures.Add("Mobius", err)
+		return
+	}
+
+	for _, reportName := range config.Profile.MobiusReports {
+		path := append(append([]string{}, config.Profile.MobiusPath...), reportName)
+
+		// Mobius gets stuck now and then (see the "No dice" hack in ClickItem), so every attempt after the first one starts
+		// over from a fresh page.
+		goToReport := func(ctx context.Context, attemptNumber int, path []string) error {
+			if attemptNumber > 1 {
+				err := mobiusInstance.Reload(ctx)
+				if err != nil {
+					return err
+				}
 			}
+			return mobiusInstance.GoToReport(ctx, path)
+		}
 
-			err = erpInstance.Login(config.ERPUsername, config.ERPPassword)
+		var dateFile string
+		err := config.retry(ctx, reportName, func(ctx context.Context, attemptNumber int) error {
+			err := goToReport(ctx, attemptNumber, path)
 			if err != nil {
 				return err
 			}
 
-			mobiusInstance, err := erpInstance.Mobius()
+			itemMap, err := mobiusInstance.GetItemsN(ctx, 400)
 			if err != nil {
 				return err
 			}
-
-			reportNames := []string{
-				"DGL060",
-				"DGL114",
-				"DGL115",
+			dateFile = findDateFile(itemMap, config.TargetYear, config.TargetMonth)
+			if dateFile == "" {
+				return fmt.Errorf("could not find a date file")
 			}
-			for _, reportName := range reportNames {
-				path := []string{"Repositories", "First State Financials", "Reports", reportName}
-
-				err := mobiusInstance.GoToReport(path)
-				if err != nil {
-					return err
-				}
-
-				var dateFile string
-				{
-					itemMap, err := mobiusInstance.GetItemsN(400)
-					if err != nil {
-						return err
-					}
-
-					lastDateOfMonth := time.Date(config.TargetYear, time.Month(config.TargetMonth)+1, 1, 0, 0, -1, 0, time.Local)
-					var lastDate time.Time
-					var lastDateFile string
-					var firstDateAfterMonth time.Time
-					var firstDateAfterMonthFile string
-					for dateName := range maps.Keys(itemMap) {
-						t, err := time.Parse("Jan 2, 2006 3:04:05 PM", dateName)
-						if err != nil {
-							fmt.Printf("Could not parse date %q: %v\n", dateName, err)
-							continue
-						}
-						if t.After(lastDateOfMonth) && (firstDateAfterMonth.IsZero() || t.Before(firstDateAfterMonth)) {
-							firstDateAfterMonth = t
-							firstDateAfterMonthFile = dateName
-						}
-						if t.Year() != config.TargetYear || int(t.Month()) != config.TargetMonth {
-							continue
-						}
-						if lastDate.IsZero() || t.After(lastDate) {
-							lastDate = t
-							lastDateFile = dateName
-						}
-					}
-
-					if !firstDateAfterMonth.IsZero() {
-						dateFile = firstDateAfterMonthFile
-					}
-					if !lastDate.IsZero() {
-						if lastDate.AddDate(0, 0, 1).Month() != lastDate.Month() {
-							dateFile = lastDateFile
-						}
-					}
-				}
-				if dateFile == "" {
-					return fmt.Errorf("could not find a date file")
-				}
-
-				/*
-					err = mobiusInstance.ClickItem(dateFile)
-					if err != nil {
-						return err
-					}
-				*/
-
-				pathWithDate := append([]string{}, path...)
-				pathWithDate = append(pathWithDate, dateFile)
-
-				for _, division := range divisions {
-					fmt.Printf("Exporting report %s for division %s.\n", reportName, division)
-
-					reportFile := fmt.Sprintf("95%s00", division)
+			return nil
+		})
+		if err != nil {
+			failures.Add(reportName, err)
+			continue
+		}
 
-					err = mobiusInstance.GoToReport(path)
-					if err != nil {
-						return err
-					}
+		pathWithDate := append([]string{}, path...)
+		pathWithDate = append(pathWithDate, dateFile)
 
-					err = mobiusInstance.GoToReport(pathWithDate)
-					if err != nil {
-						return err
-					}
+		for _, division := range divisions {
+			fmt.Printf("Exporting report %s for division %s.\n", reportName, division)
 
-					fileName := config.BaseDirectory + string(filepath.Separator) + "mobius." + reportName + "." + division + ".csv"
-					if _, err := os.Stat(fileName); err != nil && os.IsNotExist(err) {
-						contents, err := mobiusInstance.ExtractReport(reportName, reportFile)
-						if err != nil {
-							return err
-						}
+			reportFile := config.Profile.mobiusReportFile(division)
 
-						os.WriteFile(fileName, contents, 0644)
-					}
+			fileName := "mobius." + reportName + "." + division + ".csv"
+			err := config.downloadReport(ctx, config.BaseDirectory+string(filepath.Separator)+fileName, func(ctx context.Context, attemptNumber int) ([]byte, error) {
+				err := goToReport(ctx, attemptNumber, path)
+				if err != nil {
+					return nil, err
+				}
+				err = mobiusInstance.GoToReport(ctx, pathWithDate)
+				if err != nil {
+					return nil, err
 				}
+				return mobiusInstance.ExtractReport(ctx, reportName, reportFile)
+			})
+			if err != nil {
+				failures.Add(fileName, err)
 			}
 		}
 	}
+}
 
-	return nil
+// findDateFile returns the name of the Mobius date file that has the numbers for the end of the given month.
+//
+// This is the last file in the month if it was made on the last day; otherwise, it is the first file after the month.
+func findDateFile(itemMap map[string]*rod.Element, targetYear int, targetMonth int) string {
+	var dateFile string
+
+	lastDateOfMonth := time.Date(targetYear, time.Month(targetMonth)+1, 1, 0, 0, -1, 0, time.Local)
+	var lastDate time.Time
+	var lastDateFile string
+	var firstDateAfterMonth time.Time
+	var firstDateAfterMonthFile string
+	for dateName := range maps.Keys(itemMap) {
+		t, err := time.Parse("Jan 2, 2006 3:04:05 PM", dateName)
+		if err != nil {
+			fmt.Printf("Could not parse date %q: %v\n", dateName, err)
+			continue
+		}
+		if t.After(lastDateOfMonth) && (firstDateAfterMonth.IsZero() || t.Before(firstDateAfterMonth)) {
+			firstDateAfterMonth = t
+			firstDateAfterMonthFile = dateName
+		}
+		if t.Year() != targetYear || int(t.Month()) != targetMonth {
+			continue
+		}
+		if lastDate.IsZero() || t.After(lastDate) {
+			lastDate = t
+			lastDateFile = dateName
+		}
+	}
+
+	if !firstDateAfterMonth.IsZero() {
+		dateFile = firstDateAfterMonthFile
+	}
+	if !lastDate.IsZero() {
+		if lastDate.AddDate(0, 0, 1).Month() != lastDate.Month() {
+			dateFile = lastDateFile
+		}
+	}
+	return dateFile
 }
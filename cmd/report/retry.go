@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tekkamanendless/cboc-tools/scrape"
+)
+
+// retry calls the attempt until it succeeds, waiting longer and longer between attempts.
+//
+// Each attempt gets its own step timeout.  The attempt number starts at 1, so that the attempt can tell whether it has
+// to clean up after an earlier one (such as by reloading the page).
+func (c Config) retry(ctx context.Context, name string, attempt func(ctx context.Context, attemptNumber int) error) error {
+	delay := c.RetryDelay
+	for attemptNumber := 1; ; attemptNumber++ {
+		stepCtx, cancel := c.stepContext(ctx)
+		err := attempt(stepCtx, attemptNumber)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		// There is no point in trying again once the whole run is out of time.
+		if attemptNumber >= c.Attempts || ctx.Err() != nil {
+			return err
+		}
+
+		fmt.Printf("Attempt %d of %d for %s failed: %v\n", attemptNumber, c.Attempts, name, err)
+		fmt.Printf("Trying again in %v...\n", delay)
+		if sleepErr := scrape.Sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+		delay *= 2
+		if c.MaxRetryDelay > 0 && delay > c.MaxRetryDelay {
+			delay = c.MaxRetryDelay
+		}
+	}
+}
+
+// downloadReport saves the report to the file, unless the file is already there.
+//
+// An empty file is left over from a download that did not finish, so it is downloaded again.  The download is
+// retried as needed.
+func (c Config) downloadReport(ctx context.Context, fileName string, download func(ctx context.Context, attemptNumber int) ([]byte, error)) error {
+	info, err := os.Stat(fileName)
+	if err == nil {
+		if info.Size() > 0 {
+			return nil
+		}
+		fmt.Printf("%s is empty; downloading it again.\n", fileName)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var contents []byte
+	err = c.retry(ctx, filepath.Base(fileName), func(ctx context.Context, attemptNumber int) error {
+		var err error
+		contents, err = download(ctx, attemptNumber)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, contents, 0644)
+}
+
+// Failure is something that could not be done, even after retrying.
+type Failure struct {
+	Name string // This is what was being done, such as "mobius.DGL060.95.csv".
+	Err  error
+}
+
+// Failures collects what could not be done so that the rest of the run can carry on.
+type Failures []Failure
+
+// Add records a failure.
+func (f *Failures) Add(name string, err error) {
+	fmt.Printf("FAILED: %s: %v\n", name, err)
+	*f = append(*f, Failure{Name: name, Err: err})
+}
+
+// Err returns nil if nothing failed; otherwise, it returns an error that lists every failure.
+func (f Failures) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	errs := []error{fmt.Errorf("%d failure(s)", len(f))}
+	for _, failure := range f {
+		errs = append(errs, fmt.Errorf("%s: %w", failure.Name, failure.Err))
+	}
+	return errors.Join(errs...)
+}
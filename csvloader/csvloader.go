@@ -0,0 +1,371 @@
+// Package csvloader reads the CSV exports from FSF and Mobius into the database models.
+//
+// The columns are matched up using "csv" struct tags, such as:
+//
+//	BudgetedAmount Money     `csv:"budgetamt"`
+//	FiscalYear     int       `csv:"fy,year,required"`
+//	EndDate        time.Time `csv:"end_date,required,layout=01/02/06"`
+//
+// The first part of the tag is the (case-insensitive) header of the column.  The options are:
+//
+//	required       The row is rejected if the value is empty.
+//	optional       The column does not have to be in the file.
+//	year           A two-digit year is taken to be in the 2000s.
+//	month          The value may be a month number or a month name.
+//	layout=A|B|C   The date layouts to try, in order.
+package csvloader
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// defaultLayouts are the date layouts to try when a field does not list its own.
+var defaultLayouts = []string{"01/02/2006", "1/2/2006", "01/02/06", "1/2/06", "2006-01-02"}
+
+// Result is everything that was loaded from a file.
+type Result[T any] struct {
+	Records        []T      // These are the rows that were loaded successfully.
+	Lines          []int    // This is the line of the file that each record came from.
+	Rejects        []Reject // These are the rows that could not be loaded.
+	Headers        []string // These are the normalized headers from the file.
+	MissingColumns []string // These are the tagged columns that the file does not have, not counting the optional ones.
+	Duplicates     int      // This is the number of rows that were skipped because they were exact copies of an earlier row.
+}
+
+// Reject describes a value that could not be loaded.
+type Reject struct {
+	Line   int    // This is the line of the file.
+	Column string // This is the header of the column, if the problem was with a single column.
+	Value  string // This is the raw value.
+	Reason string
+}
+
+func (r Reject) Error() string {
+	if r.Column == "" {
+		return fmt.Sprintf("line %d: %s", r.Line, r.Reason)
+	}
+	return fmt.Sprintf("line %d: %s: %s", r.Line, r.Column, r.Reason)
+}
+
+// field is a struct field with a "csv" tag.
+type field struct {
+	index    int
+	column   string
+	required bool
+	optional bool
+	year     bool
+	month    bool
+	layouts  []string
+}
+
+// Reader reads one record at a time, so that a large file never has to be held in memory.
+//
+// A row with any value that cannot be parsed is rejected as a whole and skipped; those are collected in Rejects.
+type Reader[T any] struct {
+	Headers        []string // These are the normalized headers from the file.
+	MissingColumns []string // These are the tagged columns that the file does not have, not counting the optional ones.
+	Rejects        []Reject // These are the rows that could not be loaded so far.
+	Duplicates     int      // This is the number of rows so far that were skipped because they were exact copies of an earlier row.
+
+	csvReader *csv.Reader
+	fields    []field
+	headerMap map[string]int
+	seen      map[[sha256.Size]byte]struct{} // Only a hash of each row is kept, rather than the row itself.
+}
+
+// NewReader reads the header of the CSV.
+//
+// An empty file has no headers, and every call to Read will return io.EOF.
+func NewReader[T any](r io.Reader) (*Reader[T], error) {
+	fields, err := parseFields(reflect.TypeFor[T]())
+	if err != nil {
+		return nil, err
+	}
+
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1 // Some exports have a ragged last line.
+	csvReader.LazyQuotes = true    // Formulas like `="00123"` are not quoted.
+	csvReader.ReuseRecord = true
+
+	reader := &Reader[T]{
+		csvReader: csvReader,
+		fields:    fields,
+		headerMap: map[string]int{},
+		seen:      map[[sha256.Size]byte]struct{}{},
+	}
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return reader, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+	for i, column := range header {
+		column = NormalizeHeader(column)
+		reader.Headers = append(reader.Headers, column)
+		if _, ok := reader.headerMap[column]; !ok {
+			reader.headerMap[column] = i
+		}
+	}
+	for _, f := range fields {
+		if _, ok := reader.headerMap[f.column]; !ok && !f.optional {
+			reader.MissingColumns = append(reader.MissingColumns, f.column)
+		}
+	}
+	return reader, nil
+}
+
+// Read returns the next record and the line of the file that it came from.
+//
+// At the end of the file, this returns io.EOF.  Any other error is a problem with the file itself.
+func (r *Reader[T]) Read() (T, int, error) {
+	var record T
+	if r.Headers == nil {
+		return record, 0, io.EOF
+	}
+	for {
+		row, err := r.csvReader.Read()
+		if err == io.EOF {
+			return record, 0, io.EOF
+		}
+		if err != nil {
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				r.Rejects = append(r.Rejects, Reject{Line: parseError.Line, Reason: parseError.Err.Error()})
+				continue
+			}
+			return record, 0, fmt.Errorf("could not read row: %w", err)
+		}
+		line, _ := r.csvReader.FieldPos(0)
+
+		key := sha256.Sum256([]byte(strings.Join(row, "\x00")))
+		if _, ok := r.seen[key]; ok {
+			r.Duplicates++
+			continue
+		}
+		r.seen[key] = struct{}{}
+
+		reject := setFields(reflect.ValueOf(&record).Elem(), r.fields, r.headerMap, row)
+		if reject != nil {
+			reject.Line = line
+			r.Rejects = append(r.Rejects, *reject)
+			record = *new(T)
+			continue
+		}
+		return record, line, nil
+	}
+}
+
+// Load reads every row of the CSV into a record.
+//
+// This is handy for small files; use a Reader for anything large.
+func Load[T any](r io.Reader) (*Result[T], error) {
+	reader, err := NewReader[T](r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result[T]{}
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Records = append(result.Records, record)
+		result.Lines = append(result.Lines, line)
+	}
+	result.Headers = reader.Headers
+	result.MissingColumns = reader.MissingColumns
+	result.Rejects = reader.Rejects
+	result.Duplicates = reader.Duplicates
+	return result, nil
+}
+
+// Decode fills in a record from values that are keyed by column header, using the same rules as a CSV file.
+//
+// This lets other report formats be loaded into the same models.  If a value cannot be parsed, then the Reject says why; the error is only for problems with the struct itself.
+func Decode[T any](values map[string]string) (T, *Reject, error) {
+	var record T
+	fields, err := parseFields(reflect.TypeFor[T]())
+	if err != nil {
+		return record, nil, err
+	}
+
+	headerMap := map[string]int{}
+	var row []string
+	for column, value := range values {
+		headerMap[NormalizeHeader(column)] = len(row)
+		row = append(row, value)
+	}
+	reject := setFields(reflect.ValueOf(&record).Elem(), fields, headerMap, row)
+	if reject != nil {
+		return *new(T), reject, nil
+	}
+	return record, nil, nil
+}
+
+// NormalizeHeader turns a header into the form that the tags use.
+func NormalizeHeader(header string) string {
+	header = strings.TrimPrefix(header, "\ufeff") // Excel likes to start files with a byte-order mark.
+	header = strings.TrimSpace(header)
+	header = strings.ToLower(header)
+	return header
+}
+
+// NormalizeValue cleans up a single value.
+//
+// Spreadsheet exports sometimes wrap a value in a formula (such as `="00123"`) to keep leading zeros.
+func NormalizeValue(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "=") {
+		value = strings.TrimPrefix(value, "=")
+		if strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) && len(value) >= 2 {
+			value = value[1 : len(value)-1]
+		}
+		value = strings.TrimSpace(value)
+	}
+	return value
+}
+
+// normalizeNumber turns accounting notation into something that can be parsed.
+//
+// Negative numbers are shown in parentheses (or with a trailing minus sign on mainframe reports), and large numbers have thousands separators.
+func normalizeNumber(value string) string {
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		value = "-" + strings.TrimSpace(value[1:len(value)-1])
+	} else if strings.HasSuffix(value, "-") && len(value) > 1 {
+		value = "-" + strings.TrimSpace(strings.TrimSuffix(value, "-"))
+	}
+	value = strings.ReplaceAll(value, ",", "")
+	return value
+}
+
+// parseFields finds the tagged fields of the struct.
+func parseFields(t reflect.Type) ([]field, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%v is not a struct", t)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("csv")
+		if !ok || tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		f := field{
+			index:  i,
+			column: NormalizeHeader(parts[0]),
+		}
+		for _, option := range parts[1:] {
+			switch {
+			case option == "required":
+				f.required = true
+			case option == "optional":
+				f.optional = true
+			case option == "year":
+				f.year = true
+			case option == "month":
+				f.month = true
+			case strings.HasPrefix(option, "layout="):
+				f.layouts = strings.Split(strings.TrimPrefix(option, "layout="), "|")
+			default:
+				return nil, fmt.Errorf("field %s: unknown option %q", t.Field(i).Name, option)
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// setFields fills in the record from the row.
+func setFields(record reflect.Value, fields []field, headerMap map[string]int, row []string) *Reject {
+	for _, f := range fields {
+		var value string
+		if i, ok := headerMap[f.column]; ok && i < len(row) {
+			value = NormalizeValue(row[i])
+		}
+		if value == "" {
+			if f.required {
+				return &Reject{Column: f.column, Reason: "missing value"}
+			}
+			continue
+		}
+
+		err := setField(record.Field(f.index), f, value)
+		if err != nil {
+			return &Reject{Column: f.column, Value: value, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// setField parses the value into the field.
+func setField(target reflect.Value, f field, value string) error {
+	switch target.Addr().Interface().(type) {
+	case *databasemodel.Money:
+		v, err := databasemodel.ParseMoney(normalizeNumber(value))
+		if err != nil {
+			return err
+		}
+		target.SetInt(int64(v))
+		return nil
+	case *time.Time:
+		layouts := f.layouts
+		if len(layouts) == 0 {
+			layouts = defaultLayouts
+		}
+		for _, layout := range layouts {
+			v, err := time.Parse(layout, value)
+			if err == nil {
+				target.Set(reflect.ValueOf(v))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid date: %q", value)
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(value)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		v, err := strconv.ParseInt(normalizeNumber(value), 10, 64)
+		if err != nil {
+			if !f.month {
+				return fmt.Errorf("invalid number: %q", value)
+			}
+			// The month may be spelled out, just like it is in the report form.
+			t, err := time.Parse("January", value)
+			if err != nil {
+				return fmt.Errorf("invalid month: %q", value)
+			}
+			v = int64(t.Month())
+		}
+		if f.year && v < 100 {
+			v += 2000
+		}
+		target.SetInt(v)
+	case reflect.Float64, reflect.Float32:
+		v, err := strconv.ParseFloat(normalizeNumber(value), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number: %q", value)
+		}
+		target.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported field type: %v", target.Type())
+	}
+	return nil
+}
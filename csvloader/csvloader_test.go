@@ -0,0 +1,217 @@
+package csvloader
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// testRecord has one field for each of the tag options.
+type testRecord struct {
+	Name     string              `csv:"name,required"`
+	Amount   databasemodel.Money `csv:"amount"`
+	Year     int                 `csv:"fy,year"`
+	Month    int                 `csv:"month,month,optional"`
+	Date     time.Time           `csv:"date,layout=01/02/06|2006-01-02"`
+	Count    int                 `csv:"count,optional"`
+	Ignored  string              `csv:"-"`
+	Untagged string
+}
+
+func TestLoad(t *testing.T) {
+	contents := strings.Join([]string{
+		"\ufeff Name ,AMOUNT,FY,Month,Date,Extra",
+		`Alpha,"1,234.56",25,9,09/30/24,x`,
+		`Beta,(25.00),2024,September,2024-09-30,x`,
+		`Gamma,50.00-,24,,,x`,
+		`="00123",$1.5,,12,,x`,
+	}, "\n")
+	result, err := Load[testRecord](strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []testRecord{
+		{Name: "Alpha", Amount: 123456, Year: 2025, Month: 9, Date: time.Date(2024, time.September, 30, 0, 0, 0, 0, time.UTC)},
+		{Name: "Beta", Amount: -2500, Year: 2024, Month: 9, Date: time.Date(2024, time.September, 30, 0, 0, 0, 0, time.UTC)},
+		{Name: "Gamma", Amount: -5000, Year: 2024},
+		// The formula keeps the leading zeros.
+		{Name: "00123", Amount: 150, Month: 12},
+	}
+	if !reflect.DeepEqual(result.Records, expected) {
+		t.Errorf("Records:\nexpected %+v\n     got %+v", expected, result.Records)
+	}
+	if !reflect.DeepEqual(result.Lines, []int{2, 3, 4, 5}) {
+		t.Errorf("Wrong lines: %v", result.Lines)
+	}
+	if !reflect.DeepEqual(result.Headers, []string{"name", "amount", "fy", "month", "date", "extra"}) {
+		t.Errorf("Wrong headers: %q", result.Headers)
+	}
+	// The "count" column is optional, so it is not missing.
+	if len(result.MissingColumns) != 0 {
+		t.Errorf("Expected no missing columns; got %v", result.MissingColumns)
+	}
+	if len(result.Rejects) != 0 {
+		t.Errorf("Expected no rejects; got %+v", result.Rejects)
+	}
+}
+
+func TestLoadMissingColumns(t *testing.T) {
+	result, err := Load[testRecord](strings.NewReader("name,amount\nAlpha,1.00\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Only the columns that are not optional are missing.
+	if !reflect.DeepEqual(result.MissingColumns, []string{"fy", "date"}) {
+		t.Errorf("Wrong missing columns: %v", result.MissingColumns)
+	}
+	// The rows are still loaded; it is up to the caller to decide whether that is good enough.
+	if len(result.Records) != 1 || result.Records[0].Amount != 100 {
+		t.Errorf("Wrong records: %+v", result.Records)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	contents := strings.Join([]string{
+		"name,amount,fy,month,date",
+		",1.00,25,9,09/30/24",       // The name is required.
+		"Alpha,abc,25,9,09/30/24",   // The amount is not a number.
+		"Beta,1.005,25,9,09/30/24",  // That is a fraction of a cent.
+		"Gamma,1.00,xx,9,09/30/24",  // The year is not a number.
+		"Delta,1.00,25,Smarch,",     // That is not a month.
+		"Epsilon,1.00,25,9,9/30/24", // The date does not match either layout.
+		"Zeta,1.00,25,9,09/30/24",
+	}, "\n")
+	result, err := Load[testRecord](strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Records) != 1 || result.Records[0].Name != "Zeta" {
+		t.Errorf("Expected only Zeta to load; got %+v", result.Records)
+	}
+	expected := []struct {
+		line   int
+		column string
+	}{
+		{2, "name"},
+		{3, "amount"},
+		{4, "amount"},
+		{5, "fy"},
+		{6, "month"},
+		{7, "date"},
+	}
+	if len(result.Rejects) != len(expected) {
+		t.Fatalf("Expected %d rejects; got %d: %+v", len(expected), len(result.Rejects), result.Rejects)
+	}
+	for i, reject := range result.Rejects {
+		if reject.Line != expected[i].line || reject.Column != expected[i].column {
+			t.Errorf("Reject %d: expected line %d, column %s; got %+v", i, expected[i].line, expected[i].column, reject)
+		}
+	}
+}
+
+func TestReaderDuplicates(t *testing.T) {
+	contents := strings.Join([]string{
+		"name,amount,fy,date",
+		"Alpha,1.00,25,09/30/24",
+		"Alpha,1.00,25,09/30/24",
+		"Alpha,2.00,25,09/30/24",
+		"Alpha,1.00,25,09/30/24",
+	}, "\n")
+	reader, err := NewReader[testRecord](strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var amounts []databasemodel.Money
+	for {
+		record, _, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		amounts = append(amounts, record.Amount)
+	}
+	if !reflect.DeepEqual(amounts, []databasemodel.Money{100, 200}) {
+		t.Errorf("Wrong amounts: %v", amounts)
+	}
+	if reader.Duplicates != 2 {
+		t.Errorf("Expected 2 duplicates; got %d.", reader.Duplicates)
+	}
+}
+
+func TestReaderEmpty(t *testing.T) {
+	reader, err := NewReader[testRecord](strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(reader.Headers) != 0 {
+		t.Errorf("Expected no headers; got %v", reader.Headers)
+	}
+	_, _, err = reader.Read()
+	if err != io.EOF {
+		t.Errorf("Expected io.EOF; got %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	record, reject, err := Decode[testRecord](map[string]string{
+		"NAME":   " Alpha ",
+		"amount": "12.50-",
+		"fy":     "25",
+		"date":   "2024-09-30",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reject != nil {
+		t.Fatalf("Unexpected reject: %v", reject)
+	}
+	expected := testRecord{Name: "Alpha", Amount: -1250, Year: 2025, Date: time.Date(2024, time.September, 30, 0, 0, 0, 0, time.UTC)}
+	if record != expected {
+		t.Errorf("Expected %+v; got %+v", expected, record)
+	}
+
+	_, reject, err = Decode[testRecord](map[string]string{"amount": "1.00"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reject == nil || reject.Column != "name" {
+		t.Errorf("Expected the missing name to be rejected; got %v", reject)
+	}
+}
+
+func TestDecodeBadTag(t *testing.T) {
+	type badRecord struct {
+		Name string `csv:"name,sometimes"`
+	}
+	_, _, err := Decode[badRecord](map[string]string{"name": "Alpha"})
+	if err == nil {
+		t.Errorf("Expected an error for the unknown option.")
+	}
+}
+
+func TestNormalizeNumber(t *testing.T) {
+	rows := []struct {
+		input  string
+		output string
+	}{
+		{"1,234.56", "1234.56"},
+		{"(1,234.56)", "-1234.56"},
+		{"( 5.00 )", "-5.00"},
+		{"1,234.56-", "-1234.56"},
+		{"-", "-"},
+		{"12", "12"},
+	}
+	for _, row := range rows {
+		output := normalizeNumber(row.input)
+		if output != row.output {
+			t.Errorf("%q: expected %q; got %q.", row.input, row.output, output)
+		}
+	}
+}
@@ -6,16 +6,33 @@ import (
 	"gorm.io/gorm"
 )
 
-func Apply(db *gorm.DB) error {
-	err := db.AutoMigrate(
+// Models returns every table in the database.
+func Models() []any {
+	return []any{
+		&Import{},
 		&FSFOperatingUnitExpenditureSummary{},
 		&FSFOperatingUnitProgramSummary{},
+		&FSFDetailedActivity{},
 		&MobiusDGL060{},
 		&MobiusDGL114{},
 		&MobiusDGL115{},
-	)
-	if err != nil {
-		return fmt.Errorf("could not migrate tables: %w", err)
 	}
-	return nil
+}
+
+// Apply creates or updates the tables.
+//
+// Tables from older versions of these tools are fixed up first.  Everything happens in one transaction, so a failure
+// leaves the database as it was; otherwise, a conversion could end up being applied twice.
+func Apply(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := migrate(tx)
+		if err != nil {
+			return fmt.Errorf("could not update old tables: %w", err)
+		}
+		err = tx.AutoMigrate(Models()...)
+		if err != nil {
+			return fmt.Errorf("could not migrate tables: %w", err)
+		}
+		return nil
+	})
 }
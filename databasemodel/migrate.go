@@ -0,0 +1,135 @@
+package databasemodel
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// migration brings a table made by an older version of these tools up to date before AutoMigrate runs.
+//
+// Each migration checks the table for itself, so it only does something the first time that it sees an old table.
+type migration func(db *gorm.DB, model any) error
+
+// migrations are run in order on every model.
+var migrations = []migration{
+	migrateRenamedColumns,
+	migrateMoneyToCents,
+}
+
+// migrate runs every migration on every model.
+func migrate(db *gorm.DB) error {
+	for _, model := range Models() {
+		for _, m := range migrations {
+			err := m(db, model)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnTypes returns the declared type of each column in the model's table, in upper case.
+//
+// If the table does not exist yet, then this returns nil.
+func columnTypes(db *gorm.DB, model any) (map[string]string, error) {
+	if !db.Migrator().HasTable(model) {
+		return nil, nil
+	}
+	types, err := db.Migrator().ColumnTypes(model)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the columns: %w", err)
+	}
+	output := map[string]string{}
+	for _, columnType := range types {
+		output[columnType.Name()] = strings.ToUpper(columnType.DatabaseTypeName())
+	}
+	return output, nil
+}
+
+// renamedColumns maps the old name of a column to its new name, for each model whose columns have been renamed.
+var renamedColumns = map[reflect.Type]map[string]string{
+	// The period used to be called the fiscal year and month, even though it is the calendar year and month.
+	reflect.TypeOf(Import{}): {
+		"fiscal_year":  "period_year",
+		"fiscal_month": "period_month",
+	},
+	reflect.TypeOf(FSFOperatingUnitExpenditureSummary{}): {
+		"fiscal_year":  "period_year",
+		"fiscal_month": "period_month",
+	},
+	reflect.TypeOf(FSFOperatingUnitProgramSummary{}): {
+		"fiscal_year":  "period_year",
+		"fiscal_month": "period_month",
+	},
+}
+
+// migrateRenamedColumns renames the columns that have new names, keeping their data.
+//
+// Otherwise, AutoMigrate would add empty columns under the new names and leave the data behind in the old ones.
+func migrateRenamedColumns(db *gorm.DB, model any) error {
+	renames := renamedColumns[reflect.TypeOf(model).Elem()]
+	if len(renames) == 0 {
+		return nil
+	}
+	types, err := columnTypes(db, model)
+	if err != nil {
+		return err
+	}
+	for oldName, newName := range renames {
+		if _, ok := types[oldName]; !ok {
+			continue
+		}
+		if _, ok := types[newName]; ok {
+			continue
+		}
+		fmt.Printf("Renaming column %s of %T to %s.\n", oldName, model, newName)
+		err = db.Migrator().RenameColumn(model, oldName, newName)
+		if err != nil {
+			return fmt.Errorf("could not rename column %s to %s: %w", oldName, newName, err)
+		}
+	}
+	return nil
+}
+
+// migrateMoneyToCents converts amounts that were stored as dollars into cents.
+//
+// Amounts used to be stored as REAL dollars; they are now INTEGER cents under the same column names.  This has to
+// run before AutoMigrate changes the column type, since that is the only way to tell the two apart.
+func migrateMoneyToCents(db *gorm.DB, model any) error {
+	types, err := columnTypes(db, model)
+	if err != nil {
+		return err
+	}
+	if types == nil {
+		return nil
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	err = stmt.Parse(model)
+	if err != nil {
+		return fmt.Errorf("could not parse model %T: %w", model, err)
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || field.FieldType != reflect.TypeOf(Money(0)) {
+			continue
+		}
+		switch types[field.DBName] {
+		case "REAL", "FLOAT", "DOUBLE", "NUMERIC", "DECIMAL":
+		default:
+			continue
+		}
+
+		fmt.Printf("Converting %s.%s from dollars to cents.\n", stmt.Table, field.DBName)
+		err = db.Exec(
+			fmt.Sprintf("UPDATE %s SET %s = CAST(ROUND(%s * 100) AS INTEGER) WHERE %s IS NOT NULL", stmt.Quote(stmt.Table), stmt.Quote(field.DBName), stmt.Quote(field.DBName), stmt.Quote(field.DBName)),
+		).Error
+		if err != nil {
+			return fmt.Errorf("could not convert %s.%s to cents: %w", stmt.Table, field.DBName, err)
+		}
+	}
+	return nil
+}
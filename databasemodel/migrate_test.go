@@ -0,0 +1,130 @@
+package databasemodel
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDatabase opens an empty database file.
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Could not open the database: %v", err)
+	}
+	return db
+}
+
+func TestApplyConvertsDollarsToCents(t *testing.T) {
+	db := openTestDatabase(t)
+
+	// This is what the table looked like when amounts were stored as dollars.
+	err := db.Exec(`CREATE TABLE fsf_detailed_activities (import_id integer, division text, amount real)`).Error
+	if err != nil {
+		t.Fatalf("Could not create the old table: %v", err)
+	}
+	err = db.Exec(`INSERT INTO fsf_detailed_activities (import_id, division, amount) VALUES (1, '9533', 1234.56), (1, '9533', -0.1), (1, '9533', 100), (1, '9533', NULL)`).Error
+	if err != nil {
+		t.Fatalf("Could not fill the old table: %v", err)
+	}
+
+	// Running it twice must not convert the amounts twice.
+	for i := 0; i < 2; i++ {
+		err = Apply(db)
+		if err != nil {
+			t.Fatalf("Could not apply the model: %v", err)
+		}
+	}
+
+	var amounts []Money
+	err = db.Model(&FSFDetailedActivity{}).Order("rowid").Pluck("amount", &amounts).Error
+	if err != nil {
+		t.Fatalf("Could not read the amounts: %v", err)
+	}
+	expected := []Money{123456, -10, 10000, 0}
+	if len(amounts) != len(expected) {
+		t.Fatalf("Expected %d amounts; got %d: %v", len(expected), len(amounts), amounts)
+	}
+	for i := range expected {
+		if amounts[i] != expected[i] {
+			t.Errorf("Amount %d: expected %d; got %d.", i, expected[i], amounts[i])
+		}
+	}
+
+	var typeName string
+	err = db.Raw(`SELECT typeof(amount) FROM fsf_detailed_activities WHERE amount IS NOT NULL LIMIT 1`).Scan(&typeName).Error
+	if err != nil {
+		t.Fatalf("Could not read the amount type: %v", err)
+	}
+	if typeName != "integer" {
+		t.Errorf("Expected the amount to be stored as an integer; got %s.", typeName)
+	}
+}
+
+func TestApplyNewDatabase(t *testing.T) {
+	db := openTestDatabase(t)
+
+	err := Apply(db)
+	if err != nil {
+		t.Fatalf("Could not apply the model: %v", err)
+	}
+	err = db.Create(&MobiusDGL115{Division: "9533", TotalFundsYearToDate: 123456}).Error
+	if err != nil {
+		t.Fatalf("Could not insert a row: %v", err)
+	}
+	// Running it again on an up-to-date database must leave the amounts alone.
+	err = Apply(db)
+	if err != nil {
+		t.Fatalf("Could not apply the model again: %v", err)
+	}
+	var record MobiusDGL115
+	err = db.First(&record).Error
+	if err != nil {
+		t.Fatalf("Could not read the row: %v", err)
+	}
+	if record.TotalFundsYearToDate != 123456 {
+		t.Errorf("Expected 123456; got %d.", record.TotalFundsYearToDate)
+	}
+}
+
+func TestApplyRenamesPeriodColumns(t *testing.T) {
+	db := openTestDatabase(t)
+
+	// This is what the table looked like when the period was called the fiscal year and month.
+	err := db.Exec(`CREATE TABLE fsf_operating_unit_expenditure_summaries (import_id integer, fiscal_year integer, fiscal_month integer, division text, expended_amount real)`).Error
+	if err != nil {
+		t.Fatalf("Could not create the old table: %v", err)
+	}
+	err = db.Exec(`INSERT INTO fsf_operating_unit_expenditure_summaries (import_id, fiscal_year, fiscal_month, division, expended_amount) VALUES (1, 2024, 9, '9533', 12.34)`).Error
+	if err != nil {
+		t.Fatalf("Could not fill the old table: %v", err)
+	}
+
+	err = Apply(db)
+	if err != nil {
+		t.Fatalf("Could not apply the model: %v", err)
+	}
+
+	if db.Migrator().HasColumn(&FSFOperatingUnitExpenditureSummary{}, "fiscal_year") {
+		t.Errorf("The old column is still there.")
+	}
+	var records []FSFOperatingUnitExpenditureSummary
+	err = db.Find(&records).Error
+	if err != nil {
+		t.Fatalf("Could not read the rows: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 row; got %d.", len(records))
+	}
+	if records[0].Year != 2024 || records[0].Month != 9 {
+		t.Errorf("Expected 2024-09; got %d-%02d.", records[0].Year, records[0].Month)
+	}
+	if records[0].ExpendedAmount != 1234 {
+		t.Errorf("Expected 1234 cents; got %d.", records[0].ExpendedAmount)
+	}
+}
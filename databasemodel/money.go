@@ -0,0 +1,120 @@
+package databasemodel
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of money, stored as a whole number of cents.
+//
+// Keeping the cents as an integer means that amounts round-trip exactly, and that SQLite can add them up without any drift.
+type Money int64
+
+// ParseMoney parses an amount like "1,234.56", "-12.5", or "$100".
+//
+// Thousands separators and a leading dollar sign are ignored.  There may be at most two digits after the decimal point.
+func ParseMoney(input string) (Money, error) {
+	s := strings.TrimSpace(input)
+	s = strings.ReplaceAll(s, ",", "")
+
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = strings.TrimPrefix(s, "-")
+	} else if strings.HasPrefix(s, "+") {
+		s = strings.TrimPrefix(s, "+")
+	}
+	s = strings.TrimPrefix(s, "$")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount: %q", input)
+	}
+	if strings.Trim(fraction, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount: %q", input)
+	}
+	if len(fraction) > 2 {
+		// Some reports pad the cents with zeros, which is fine; anything else would be a fraction of a cent.
+		if strings.Trim(fraction[2:], "0") != "" {
+			return 0, fmt.Errorf("invalid amount: %q: too many decimal places", input)
+		}
+		fraction = fraction[:2]
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+
+	var dollars int64
+	if whole != "" {
+		v, err := strconv.ParseUint(whole, 10, 63)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount: %q", input)
+		}
+		dollars = int64(v)
+	}
+	cents, err := strconv.ParseUint(fraction, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %q", input)
+	}
+	if dollars > (math.MaxInt64-int64(cents))/100 {
+		return 0, fmt.Errorf("invalid amount: %q: too large", input)
+	}
+
+	output := Money(dollars*100 + int64(cents))
+	if negative {
+		output = -output
+	}
+	return output, nil
+}
+
+// MoneyFromFloat converts a number of dollars into money, rounding to the nearest cent.
+func MoneyFromFloat(dollars float64) Money {
+	return Money(math.Round(dollars * 100))
+}
+
+// Float64 returns the number of dollars.
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String returns the amount as dollars and cents, such as "-1234.56".
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Scan reads the number of cents from the database.
+func (m *Money) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(v)
+	case float64:
+		*m = Money(math.Round(v))
+	case []byte:
+		return m.Scan(string(v))
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("could not scan money: %w", err)
+		}
+		*m = Money(i)
+	default:
+		return fmt.Errorf("could not scan money from %T", value)
+	}
+	return nil
+}
+
+// Value stores the number of cents in the database.
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
@@ -0,0 +1,125 @@
+package databasemodel
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	rows := []struct {
+		input  string
+		output Money
+		fail   bool
+	}{
+		{input: "0", output: 0},
+		{input: "1234.56", output: 123456},
+		{input: "1,234.56", output: 123456},
+		{input: "$100", output: 10000},
+		{input: "-12.5", output: -1250},
+		{input: "-$12.05", output: -1205},
+		{input: "+7.1", output: 710},
+		{input: ".07", output: 7},
+		{input: "5.", output: 500},
+		{input: "  42.00  ", output: 4200},
+		{input: "1.2300", output: 123},
+		{input: "-0.01", output: -1},
+		{input: "92233720368547758.07", output: math.MaxInt64},
+		{input: "", fail: true},
+		{input: "-", fail: true},
+		{input: ".", fail: true},
+		{input: "abc", fail: true},
+		{input: "1.2.3", fail: true},
+		{input: "1.234", fail: true},
+		{input: "1.-5", fail: true},
+		{input: "--5", fail: true},
+		{input: "92233720368547758.08", fail: true},
+	}
+	for _, row := range rows {
+		t.Run(row.input, func(t *testing.T) {
+			output, err := ParseMoney(row.input)
+			if row.fail {
+				if err == nil {
+					t.Fatalf("Expected an error; got %d.", output)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output != row.output {
+				t.Errorf("Expected %d; got %d.", row.output, output)
+			}
+		})
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	rows := []struct {
+		input  float64
+		output Money
+	}{
+		{input: 0, output: 0},
+		{input: 1234.56, output: 123456},
+		{input: 0.1 + 0.2, output: 30},
+		{input: 1.005, output: 100}, // 1.005 is really 1.00499999...
+		{input: 0.125, output: 13},
+		{input: -0.125, output: -13},
+		{input: -1234.56, output: -123456},
+	}
+	for _, row := range rows {
+		output := MoneyFromFloat(row.input)
+		if output != row.output {
+			t.Errorf("%v: expected %d; got %d.", row.input, row.output, output)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	rows := []struct {
+		input  Money
+		output string
+	}{
+		{input: 0, output: "0.00"},
+		{input: 5, output: "0.05"},
+		{input: -5, output: "-0.05"},
+		{input: 123456, output: "1234.56"},
+		{input: -123456, output: "-1234.56"},
+	}
+	for _, row := range rows {
+		output := row.input.String()
+		if output != row.output {
+			t.Errorf("%d: expected %q; got %q.", row.input, row.output, output)
+		}
+		parsed, err := ParseMoney(output)
+		if err != nil {
+			t.Errorf("%d: could not parse %q: %v", row.input, output, err)
+		} else if parsed != row.input {
+			t.Errorf("%d: round trip gave %d.", row.input, parsed)
+		}
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	rows := []struct {
+		input  any
+		output Money
+	}{
+		{input: nil, output: 0},
+		{input: int64(-123456), output: -123456},
+		{input: float64(123455.6), output: 123456},
+		{input: float64(-123455.6), output: -123456},
+		{input: "42", output: 42},
+		{input: []byte("-42"), output: -42},
+	}
+	for _, row := range rows {
+		var output Money
+		err := output.Scan(row.input)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", row.input, err)
+			continue
+		}
+		if output != row.output {
+			t.Errorf("%v: expected %d; got %d.", row.input, row.output, output)
+		}
+	}
+}
@@ -2,78 +2,128 @@ package databasemodel
 
 import "time"
 
+// Import records a single load of a report file into the database.
+//
+// Every data row points back at the import that created it, so re-loading the same file for the same period can replace the old rows.
+type Import struct {
+	ID          uint      `gorm:"column:id;primaryKey"`
+	ReportType  string    `gorm:"column:report_type;index:idx_import_period"`
+	Division    string    `gorm:"column:division;index:idx_import_period"` // This is empty for a report that covers every division, and NULL for an import from before this was recorded.
+	SourceFile  string    `gorm:"column:source_file"`
+	Year        int       `gorm:"column:period_year;index:idx_import_period"`  // This is the calendar year of the period.
+	Month       int       `gorm:"column:period_month;index:idx_import_period"` // This is the calendar month of the period.
+	ImportedAt  time.Time `gorm:"column:imported_at"`
+	RecordCount int       `gorm:"column:record_count"`
+}
+
 type FSFOperatingUnitExpenditureSummary struct {
-	District                 string  `gorm:"column:district"`
-	Division                 string  `gorm:"column:division"`
-	RecordType               string  `gorm:"column:record_type"`
-	SubType                  string  `gorm:"column:sub_type"`
-	OperatingUnit            string  `gorm:"column:operating_unit"`
-	OperatingUnitDescription string  `gorm:"column:operating_unit_description"`
-	BudgetedAmount           float64 `gorm:"column:budget_amount"`
-	EncumberedAmount         float64 `gorm:"column:encumbered_amount"`
-	ExpendedAmount           float64 `gorm:"column:expended_amount"`
+	ImportID                 uint      `gorm:"column:import_id;index"`
+	Year                     int       `gorm:"column:period_year;index:idx_fsf_ou_expenditure_period"`  // This is the calendar year of the period, not the fiscal year.
+	Month                    int       `gorm:"column:period_month;index:idx_fsf_ou_expenditure_period"` // This is the calendar month of the period, not the accounting period.
+	FiscalYear               int       `gorm:"-" csv:"fiscalyear,year,optional"`                        // This is the fiscal year from the file, if it says.
+	FiscalMonth              string    `gorm:"-" csv:"fiscalmonth,optional"`                            // This is the accounting period (a number) or the month (a name) from the file, if it says.
+	AsOfDate                 time.Time `gorm:"column:as_of_date"`                                       // This is the last day of the month.
+	District                 string    `gorm:"column:district" csv:"district"`
+	Division                 string    `gorm:"column:division" csv:"div"`
+	RecordType               string    `gorm:"column:record_type" csv:"recordtype"`
+	SubType                  string    `gorm:"column:sub_type" csv:"subtype"`
+	OperatingUnit            string    `gorm:"column:operating_unit" csv:"operatingunit"`
+	OperatingUnitDescription string    `gorm:"column:operating_unit_description" csv:"descr"`
+	BudgetedAmount           Money     `gorm:"column:budget_amount" csv:"budgetamt"`
+	EncumberedAmount         Money     `gorm:"column:encumbered_amount" csv:"encumberedamt"`
+	ExpendedAmount           Money     `gorm:"column:expended_amount" csv:"expendedamt"`
 }
 
 type FSFOperatingUnitProgramSummary struct {
-	District                 string  `gorm:"column:district"`
-	Division                 string  `gorm:"column:division"`
-	RecordType               string  `gorm:"column:record_type"`
-	OperatingUnit            string  `gorm:"column:operating_unit"`
-	OperatingUnitDescription string  `gorm:"column:operating_unit_description"`
-	ProgramCode              string  `gorm:"column:program_code"`
-	ProgramCodeDescription   string  `gorm:"column:program_code_description"`
-	BudgetedAmount           float64 `gorm:"column:budget_amount"`
-	EncumberedAmount         float64 `gorm:"column:encumbered_amount"`
-	ExpendedAmount           float64 `gorm:"column:expended_amount"`
+	ImportID                 uint      `gorm:"column:import_id;index"`
+	Year                     int       `gorm:"column:period_year;index:idx_fsf_ou_program_period"`  // This is the calendar year of the period, not the fiscal year.
+	Month                    int       `gorm:"column:period_month;index:idx_fsf_ou_program_period"` // This is the calendar month of the period, not the accounting period.
+	FiscalYear               int       `gorm:"-" csv:"fiscalyear,year,optional"`                    // This is the fiscal year from the file, if it says.
+	FiscalMonth              string    `gorm:"-" csv:"fiscalmonth,optional"`                        // This is the accounting period (a number) or the month (a name) from the file, if it says.
+	AsOfDate                 time.Time `gorm:"column:as_of_date"`                                   // This is the last day of the month.
+	District                 string    `gorm:"column:district" csv:"district"`
+	Division                 string    `gorm:"column:division" csv:"div"`
+	RecordType               string    `gorm:"column:record_type" csv:"recordtype"`
+	OperatingUnit            string    `gorm:"column:operating_unit" csv:"operatingunit"`
+	OperatingUnitDescription string    `gorm:"column:operating_unit_description" csv:"operatingunitdesc"`
+	ProgramCode              string    `gorm:"column:program_code" csv:"programcode"`
+	ProgramCodeDescription   string    `gorm:"column:program_code_description" csv:"programcodedesc"`
+	BudgetedAmount           Money     `gorm:"column:budget_amount" csv:"budgetamt"`
+	EncumberedAmount         Money     `gorm:"column:encumbered_amount" csv:"encumberedamt"`
+	ExpendedAmount           Money     `gorm:"column:expended_amount" csv:"expendedamt"`
+}
+
+type FSFDetailedActivity struct {
+	ImportID                 uint      `gorm:"column:import_id;index"`
+	District                 string    `gorm:"column:district" csv:"district"`
+	Division                 string    `gorm:"column:division" csv:"div"`
+	TransactionDate          time.Time `gorm:"column:transaction_date" csv:"accountingdate,required,layout=1/2/2006|01/02/06|2006-01-02"`
+	BudgetReference          string    `gorm:"column:budget_reference" csv:"budgetref"`
+	Fund                     string    `gorm:"column:fund" csv:"fund"`
+	OperatingUnit            string    `gorm:"column:operating_unit" csv:"operatingunit"`
+	OperatingUnitDescription string    `gorm:"column:operating_unit_description" csv:"operatingunitdesc"`
+	ProgramCode              string    `gorm:"column:program_code" csv:"programcode"`
+	ProgramCodeDescription   string    `gorm:"column:program_code_description" csv:"programcodedesc"`
+	Account                  string    `gorm:"column:account" csv:"account"`
+	AccountDescription       string    `gorm:"column:account_description" csv:"accountdesc"`
+	VendorID                 string    `gorm:"column:vendor_id" csv:"vendorid"`
+	VendorName               string    `gorm:"column:vendor_name" csv:"vendorname"`
+	DocumentType             string    `gorm:"column:document_type" csv:"documenttype"`
+	DocumentID               string    `gorm:"column:document_id" csv:"documentid"`
+	Description              string    `gorm:"column:description" csv:"description"`
+	Amount                   Money     `gorm:"column:amount" csv:"amount"`
 }
 
 type MobiusDGL060 struct {
+	ImportID                 uint      `gorm:"column:import_id;index"`
 	Division                 string    `gorm:"column:division"`
-	AsOfDate                 time.Time `gorm:"column:as_of_date"`
-	DepartmentID             string    `gorm:"column:department_id"`
-	DepartmentDescription    string    `gorm:"column:department_description"`
-	FiscalYear               int       `gorm:"column:fiscal_year"`
-	Fund                     string    `gorm:"column:fund"`
-	Appropriation            string    `gorm:"column:appropriation"`
-	AppropriationType        string    `gorm:"column:appropriation_type"`
-	AppropriationDescription string    `gorm:"column:appropriation_description"`
-	EndDate                  time.Time `gorm:"column:end_date"`
-	AvailableAmount          float64   `gorm:"column:available_amount"` // This is the total amount of money available.
-	EncumberedAmount         float64   `gorm:"column:encumbered_amount"`
-	CurrentYearExpenses      float64   `gorm:"column:current_year_expenses"`
-	PriorYearExpenses        float64   `gorm:"column:prior_year_expenses"`
-	RemainingAmount          float64   `gorm:"column:remaining_spend_authorized"`
+	AsOfDate                 time.Time `gorm:"column:as_of_date" csv:"rpt_asof_date,required,layout=01/02/06"`
+	DepartmentID             string    `gorm:"column:department_id" csv:"dept_id"`
+	DepartmentDescription    string    `gorm:"column:department_description" csv:"dept_desc"`
+	FiscalYear               int       `gorm:"column:fiscal_year" csv:"fy,year,required"`
+	Fund                     string    `gorm:"column:fund" csv:"fund"`
+	Appropriation            string    `gorm:"column:appropriation" csv:"appr"`
+	AppropriationType        string    `gorm:"column:appropriation_type" csv:"type"`
+	AppropriationDescription string    `gorm:"column:appropriation_description" csv:"appr_descr"`
+	EndDate                  time.Time `gorm:"column:end_date" csv:"end_date,required,layout=01/02/06"`
+	AvailableAmount          Money     `gorm:"column:available_amount" csv:"available_funds"` // This is the total amount of money available.
+	EncumberedAmount         Money     `gorm:"column:encumbered_amount" csv:"encumbrances"`
+	CurrentYearExpenses      Money     `gorm:"column:current_year_expenses" csv:"curr_yr_expen"`
+	PriorYearExpenses        Money     `gorm:"column:prior_year_expenses" csv:"prior_yr_expen"`
+	RemainingAmount          Money     `gorm:"column:remaining_spend_authorized" csv:"remain_spend_auth"`
 }
 
 type MobiusDGL114 struct {
+	ImportID                  uint      `gorm:"column:import_id;index"`
 	Division                  string    `gorm:"column:division"`
-	AsOfDate                  time.Time `gorm:"column:as_of_date"`
-	DepartmentID              string    `gorm:"column:department_id"`
-	DepartmentDescription     string    `gorm:"column:department_description"`
-	BudgetYear                int       `gorm:"column:budget_year"`
-	Fund                      string    `gorm:"column:fund"`
-	Appropriation             string    `gorm:"column:appropriation"`
-	AppropriationType         string    `gorm:"column:appropriation_type"`
-	RevenueAccount            string    `gorm:"column:revenue_account"`
-	RevenueAccountDescription string    `gorm:"column:revenue_account_description"`
-	LocalFundsCurrent         float64   `gorm:"column:local_funds_current"`
-	LocalFundsYearToDate      float64   `gorm:"column:local_funds_year_to_date"`
-	StateFundsCurrent         float64   `gorm:"column:state_funds_current"`
-	StateFundsYearToDate      float64   `gorm:"column:state_funds_year_to_date"`
+	AsOfDate                  time.Time `gorm:"column:as_of_date" csv:"rptasofdate,required,layout=01/02/2006|01/02/06"`
+	DepartmentID              string    `gorm:"column:department_id" csv:"deptid"`
+	DepartmentDescription     string    `gorm:"column:department_description" csv:"deptdesc"`
+	BudgetYear                int       `gorm:"column:budget_year" csv:"budref,year,required"`
+	Fund                      string    `gorm:"column:fund" csv:"fund"`
+	Appropriation             string    `gorm:"column:appropriation" csv:"apprcode"`
+	AppropriationType         string    `gorm:"column:appropriation_type" csv:"apprtype"`
+	RevenueAccount            string    `gorm:"column:revenue_account" csv:"revaccount"`
+	RevenueAccountDescription string    `gorm:"column:revenue_account_description" csv:"revdescr"`
+	LocalFundsCurrent         Money     `gorm:"column:local_funds_current" csv:"gf_current"`
+	LocalFundsYearToDate      Money     `gorm:"column:local_funds_year_to_date" csv:"gf_ytd"`
+	StateFundsCurrent         Money     `gorm:"column:state_funds_current" csv:"sf_current"`
+	StateFundsYearToDate      Money     `gorm:"column:state_funds_year_to_date" csv:"sf_ytd"`
 }
 
 type MobiusDGL115 struct {
-	Division              string  `gorm:"column:division"`
-	DepartmentID          string  `gorm:"column:department_id"`
-	DepartmentDescription string  `gorm:"column:department_description"`
-	FiscalYear            int     `gorm:"column:fiscal_year"`
-	AccountPeriod         int     `gorm:"column:account_period"`
-	Account               string  `gorm:"column:account"`
-	AccountDescription    string  `gorm:"column:account_description"`
-	LocalFundsMonthToDate float64 `gorm:"column:local_funds_month_to_date"`
-	StateFundsMonthToDate float64 `gorm:"column:state_funds_month_to_date"`
-	TotalFundsMonthToDate float64 `gorm:"column:total_funds_month_to_date"`
-	LocalFundsYearToDate  float64 `gorm:"column:local_funds_year_to_date"`
-	StateFundsYearToDate  float64 `gorm:"column:state_funds_year_to_date"`
-	TotalFundsYearToDate  float64 `gorm:"column:total_funds_year_to_date"`
+	ImportID              uint   `gorm:"column:import_id;index"`
+	Division              string `gorm:"column:division"`
+	DepartmentID          string `gorm:"column:department_id" csv:"deptid"`
+	DepartmentDescription string `gorm:"column:department_description" csv:"dept_descr"`
+	FiscalYear            int    `gorm:"column:fiscal_year" csv:"fy,year,required"`
+	AccountPeriod         int    `gorm:"column:account_period" csv:"acct_period,required"`
+	Account               string `gorm:"column:account" csv:"account"`
+	AccountDescription    string `gorm:"column:account_description" csv:"acct_descr"`
+	LocalFundsMonthToDate Money  `gorm:"column:local_funds_month_to_date" csv:"gf_mtd"`
+	StateFundsMonthToDate Money  `gorm:"column:state_funds_month_to_date" csv:"sf_mtd"`
+	TotalFundsMonthToDate Money  `gorm:"column:total_funds_month_to_date" csv:"totl_mtd"`
+	LocalFundsYearToDate  Money  `gorm:"column:local_funds_year_to_date" csv:"gf_ytd"`
+	StateFundsYearToDate  Money  `gorm:"column:state_funds_year_to_date" csv:"sf_ytd"`
+	TotalFundsYearToDate  Money  `gorm:"column:total_funds_year_to_date" csv:"totl_ytd"`
 }
@@ -1,10 +1,14 @@
 package dataservicecenter
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/tekkamanendless/cboc-tools/scrape"
 )
 
 type DataServiceCenter struct {
@@ -24,33 +28,79 @@ func New(browser *rod.Browser) *DataServiceCenter {
 	}
 }
 
-func (d *DataServiceCenter) Login(district string, username string, password string) error {
-	page := d.browser.MustPage("https://secure.dataservice.org/Logon/").MustWaitStable()
+// stepError returns the error for a step that failed.
+func stepError(step string, err error) error {
+	return &scrape.StepError{Site: "dsc", Step: step, Err: err}
+}
 
-	formElement := page.MustElement(`form#loginForm`)
-	districtSelect := formElement.MustElement(`select[name="Input.District"]`)
-	districtSelect.MustSelect(district)
+func (d *DataServiceCenter) Login(ctx context.Context, district string, username string, password string) error {
+	page, err := d.browser.Context(ctx).Page(proto.TargetCreateTarget{URL: "https://secure.dataservice.org/Logon/"})
+	if err != nil {
+		return stepError("could not open the login page", err)
+	}
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return stepError("login page did not load", err)
+	}
 
-	usernameInput := formElement.MustElement(`input[name="Input.Username"]`)
-	usernameInput.MustInput(username)
+	formElement, err := page.Element(`form#loginForm`)
+	if err != nil {
+		return stepError("login form not found", err)
+	}
+	districtSelect, err := formElement.Element(`select[name="Input.District"]`)
+	if err != nil {
+		return stepError("district list not found", err)
+	}
+	err = districtSelect.Select([]string{district}, true, rod.SelectorTypeText)
+	if err != nil {
+		return stepError(fmt.Sprintf("district %q not found", district), err)
+	}
 
-	passwordInput := formElement.MustElement(`input[name="Input.Password"]`)
-	passwordInput.MustInput(password)
+	usernameInput, err := formElement.Element(`input[name="Input.Username"]`)
+	if err != nil {
+		return stepError("username input not found", err)
+	}
+	err = usernameInput.Input(username)
+	if err != nil {
+		return stepError("could not enter the username", err)
+	}
 
-	signinButton := formElement.MustElement(`button[type="submit"]`)
-	signinButton.MustClick()
+	passwordInput, err := formElement.Element(`input[name="Input.Password"]`)
+	if err != nil {
+		return stepError("password input not found", err)
+	}
+	err = passwordInput.Input(password)
+	if err != nil {
+		return stepError("could not enter the password", err)
+	}
 
-	page.MustWaitStable()
+	signinButton, err := formElement.Element(`button[type="submit"]`)
+	if err != nil {
+		return stepError("sign-in button not found", err)
+	}
+	err = signinButton.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return stepError("could not click the sign-in button", err)
+	}
+
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return stepError("page after signing in did not load", err)
+	}
 
-	if page.MustInfo().URL == "https://secure.dataservice.org/Logon/" {
-		return fmt.Errorf("could not log in")
+	info, err := page.Info()
+	if err != nil {
+		return stepError("could not get the page after signing in", err)
+	}
+	if info.URL == "https://secure.dataservice.org/Logon/" {
+		return stepError("could not log in", nil)
 	}
 
 	d.page = page
 
-	err := d.loadApplications()
+	err = d.loadApplications(ctx)
 	if err != nil {
-		return fmt.Errorf("could not load applications: %w", err)
+		return err
 	}
 	return nil
 }
@@ -65,28 +115,51 @@ func (d *DataServiceCenter) Application(name string) (Application, error) {
 			return application, nil
 		}
 	}
-	return Application{}, fmt.Errorf("application not found")
+	return Application{}, stepError(fmt.Sprintf("application %q not found", name), nil)
 }
 
-func (d *DataServiceCenter) loadApplications() error {
+func (d *DataServiceCenter) loadApplications(ctx context.Context) error {
 	if d.page == nil {
-		return fmt.Errorf("not logged in")
+		return stepError("not logged in", nil)
 	}
 
-	cardHeaders := d.page.MustElements(`.card .card-header`)
+	cardHeaders, err := d.page.Context(ctx).Elements(`.card .card-header`)
+	if err != nil {
+		return stepError("could not list the cards", err)
+	}
 	for _, cardHeader := range cardHeaders {
-		if strings.ToLower(cardHeader.MustText()) != "applications" {
+		text, err := cardHeader.Text()
+		if err != nil {
+			return stepError("could not read a card header", err)
+		}
+		if strings.ToLower(text) != "applications" {
 			continue
 		}
 
-		listElement := cardHeader.MustParent().MustElement(`.list-group`)
-		listItems := listElement.MustElements(`a.list-group-item`)
+		card, err := cardHeader.Parent()
+		if err != nil {
+			return stepError("applications card not found", err)
+		}
+		listElement, err := card.Element(`.list-group`)
+		if err != nil {
+			return stepError("applications list not found", err)
+		}
+		listItems, err := listElement.Elements(`a.list-group-item`)
+		if err != nil {
+			return stepError("could not list the applications", err)
+		}
 		for _, listItem := range listItems {
-			applicationName := listItem.MustText()
-			applicationURL := listItem.MustProperty("href").String()
+			applicationName, err := listItem.Text()
+			if err != nil {
+				return stepError("could not read an application name", err)
+			}
+			applicationURL, err := listItem.Property("href")
+			if err != nil {
+				return stepError("could not read an application link", err)
+			}
 			d.applications = append(d.applications, Application{
 				Name: applicationName,
-				URL:  applicationURL,
+				URL:  applicationURL.String(),
 			})
 		}
 	}
@@ -95,9 +168,9 @@ func (d *DataServiceCenter) loadApplications() error {
 	return nil
 }
 
-func (d *DataServiceCenter) FSF() (*FSF, error) {
+func (d *DataServiceCenter) FSF(ctx context.Context) (*FSF, error) {
 	if d.page == nil {
-		return nil, fmt.Errorf("not logged in")
+		return nil, stepError("not logged in", nil)
 	}
 
 	application, err := d.Application("Finance Reporting (FSF)")
@@ -105,12 +178,22 @@ func (d *DataServiceCenter) FSF() (*FSF, error) {
 		return nil, err
 	}
 
-	d.page.Navigate(application.URL)
-	d.page.MustWaitStable()
+	page := d.page.Context(ctx)
+	err = page.Navigate(application.URL)
+	if err != nil {
+		return nil, stepError("could not open FSF", err)
+	}
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return nil, stepError("FSF did not load", err)
+	}
 	fsf := &FSF{
 		page: d.page,
 	}
-	fsf.loadItems()
+	err = fsf.loadItems(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	return fsf, nil
 }
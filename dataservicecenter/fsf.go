@@ -1,6 +1,7 @@
 package dataservicecenter
 
 import (
+	"context"
 	"fmt"
 	"slices"
 	"strings"
@@ -8,6 +9,8 @@ import (
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/input"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/tekkamanendless/cboc-tools/scrape"
 )
 
 type FSF struct {
@@ -21,19 +24,37 @@ type FSFItem struct {
 	URL      string
 }
 
-func (f *FSF) loadItems() error {
-	itemLists := f.page.MustElements(`td ol`)
+// fsfError returns the error for a step in FSF that failed.
+func fsfError(step string, err error) error {
+	return &scrape.StepError{Site: "fsf", Step: step, Err: err}
+}
+
+func (f *FSF) loadItems(ctx context.Context) error {
+	page := f.page.Context(ctx)
+	itemLists, err := page.Elements(`td ol`)
+	if err != nil {
+		return fsfError("could not list the reports", err)
+	}
 	for _, itemList := range itemLists {
 		// TODO: Get the category.
 
-		items := itemList.MustElements(`li a`)
+		items, err := itemList.Elements(`li a`)
+		if err != nil {
+			return fsfError("could not list the reports", err)
+		}
 		for _, item := range items {
-			itemName := item.MustText()
-			itemURL := item.MustProperty("href").String()
+			itemName, err := item.Text()
+			if err != nil {
+				return fsfError("could not read a report name", err)
+			}
+			itemURL, err := item.Property("href")
+			if err != nil {
+				return fsfError("could not read a report link", err)
+			}
 			f.items = append(f.items, FSFItem{
 				// TODO: Category: category,
 				Name: itemName,
-				URL:  itemURL,
+				URL:  itemURL.String(),
 			})
 		}
 	}
@@ -52,218 +73,291 @@ func (f *FSF) Item(name string) (FSFItem, error) {
 			return item, nil
 		}
 	}
-	return FSFItem{}, fmt.Errorf("item not found")
+	return FSFItem{}, fsfError(fmt.Sprintf("report %q not found", name), nil)
 }
 
-func (f *FSF) DownloadOperatingUnitProgramSummaryReport(year int, month int, totalsOnly bool, format string) ([]byte, error) {
-	item, err := f.Item("Operating Unit/Program Expenditure Summary")
+func (f *FSF) DownloadOperatingUnitProgramSummaryReport(ctx context.Context, year int, month int, totalsOnly bool, format string) ([]byte, error) {
+	err := f.open(ctx, "Operating Unit/Program Expenditure Summary")
 	if err != nil {
 		return nil, err
 	}
 
-	f.page.MustNavigate(item.URL)
-	f.page.MustWaitStable()
-
-	f.page.MustElement(`select[name="ddlFiscalYear"]`).MustSelect(fmt.Sprintf("%d", year))
-	f.page.MustElement(`select[name="ddlFiscalMonth"]`).MustSelect(time.Month(month).String())
-	if totalsOnly {
-		f.page.MustElement(`input[name="chkOperatingUnitTotals"]`).MustClick()
+	err = f.selectPeriod(ctx, year, month)
+	if err != nil {
+		return nil, err
 	}
-	formatElement := f.page.MustElement(`select[name="ddlFormat"]`)
-	{
-		selected := false
-		formatOptions := formatElement.MustElements(`option`)
-		for _, formatOption := range formatOptions {
-			if strings.Contains(strings.ToLower(formatOption.MustText()), strings.ToLower(format)) {
-				fmt.Printf("Found the format: %s\n", formatOption.MustText())
-				formatElement.MustSelect(formatOption.MustText())
-				selected = true
-				break
-			}
+	if totalsOnly {
+		totalsInput, err := f.page.Context(ctx).Element(`input[name="chkOperatingUnitTotals"]`)
+		if err != nil {
+			return nil, fsfError("totals checkbox not found", err)
 		}
-		if !selected {
-			return nil, fmt.Errorf("format not found")
+		err = totalsInput.Click(proto.InputMouseButtonLeft, 1)
+		if err != nil {
+			return nil, fsfError("could not click the totals checkbox", err)
 		}
 	}
+	err = f.selectFormat(ctx, format)
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Printf("Waiting for download.\n")
-	download := f.page.Browser().MustWaitDownload()
+	return f.download(ctx)
+}
 
-	f.page.MustElement(`input[type="submit"]`).MustClick()
+func (f *FSF) DownloadOperatingUnitExpenditureSummaryReport(ctx context.Context, year int, month int, divisions []string, format string) ([]byte, error) {
+	err := f.open(ctx, "Operating Unit Expenditure Summary")
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Printf("Downloading...\n")
-	contents := download()
-	fmt.Printf("Downloaded %d bytes.\n", len(contents))
+	err = f.selectPeriod(ctx, year, month)
+	if err != nil {
+		return nil, err
+	}
+	err = f.selectDivisions(ctx, divisions)
+	if err != nil {
+		return nil, err
+	}
+	err = f.selectFormat(ctx, format)
+	if err != nil {
+		return nil, err
+	}
 
-	return contents, nil
+	return f.download(ctx)
 }
 
-func (f *FSF) DownloadOperatingUnitExpenditureSummaryReport(year int, month int, divisions []string, format string) ([]byte, error) {
-	item, err := f.Item("Operating Unit Expenditure Summary")
+func (f *FSF) DownloadDetailedActivityReport(ctx context.Context, startDate, endDate time.Time, divisions []string, format string) ([]byte, error) {
+	err := f.open(ctx, "Detailed Activity List")
 	if err != nil {
 		return nil, err
 	}
 
-	f.page.MustNavigate(item.URL)
-	f.page.MustWaitStable()
-
-	f.page.MustElement(`select[name="ddlFiscalYear"]`).MustSelect(fmt.Sprintf("%d", year))
-	f.page.MustElement(`select[name="ddlFiscalMonth"]`).MustSelect(time.Month(month).String())
-	if divisions != nil {
-		divisionMap := map[string]bool{}
-		for _, division := range divisions {
-			divisionMap[division] = true
-		}
+	err = f.selectDivisions(ctx, divisions)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Start date: %v\n", startDate)
+	fmt.Printf("End date: %v\n", endDate)
 
-		divisionInputs := f.page.MustElements(`#cblDivision input[type="checkbox"]`)
-		for _, divisionInput := range divisionInputs {
-			var division string
-			{
-				labelElement := divisionInput.MustParent().MustElement(`label`)
-				label := labelElement.MustText()
-				parts := strings.Split(label, " ")
-				division = parts[0]
-			}
+	err = f.enterDate(ctx, "dbxAccountingDateStart", startDate)
+	if err != nil {
+		return nil, err
+	}
+	err = f.enterDate(ctx, "dbxAccountingDateEnd", endDate)
+	if err != nil {
+		return nil, err
+	}
 
-			var checked bool
-			{
-				checkedValue := divisionInput.MustAttribute("checked")
-				if checkedValue != nil {
-					checked = *checkedValue == "checked"
-				}
-			}
+	{
+		input, err := f.page.Context(ctx).Element(`input#cbBudgetRefAll`)
+		if err != nil {
+			return nil, fsfError("all-budget-references checkbox not found", err)
+		}
+		checked, err := isChecked(input)
+		if err != nil {
+			return nil, fsfError("could not read the all-budget-references checkbox", err)
+		}
 
-			if divisionMap[division] != checked {
-				divisionInput.MustClick()
+		if !checked {
+			err = input.Click(proto.InputMouseButtonLeft, 1)
+			if err != nil {
+				return nil, fsfError("could not click the all-budget-references checkbox", err)
 			}
 		}
 	}
-	formatElement := f.page.MustElement(`select[name="ddlFormat"]`)
-	{
-		selected := false
-		formatOptions := formatElement.MustElements(`option`)
-		for _, formatOption := range formatOptions {
-			if strings.Contains(strings.ToLower(formatOption.MustText()), strings.ToLower(format)) {
-				fmt.Printf("Found the format: %s\n", formatOption.MustText())
-				formatElement.MustSelect(formatOption.MustText())
-				selected = true
-				break
-			}
-		}
-		if !selected {
-			return nil, fmt.Errorf("format not found")
-		}
+	err = f.selectFormat(ctx, format)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Waiting for download.\n")
-	download := f.page.Browser().MustWaitDownload()
+	return f.download(ctx)
+}
 
-	f.page.MustElement(`input[type="submit"]`).MustClick()
+// TODO: Total Expenditure Report
 
-	fmt.Printf("Downloading...\n")
-	contents := download()
-	fmt.Printf("Downloaded %d bytes.\n", len(contents))
+// TODO: District Revenue Report
 
-	return contents, nil
+// open goes to the form for the report with the given name.
+func (f *FSF) open(ctx context.Context, name string) error {
+	page := f.page.Context(ctx)
+	item, err := f.Item(name)
+	if err != nil {
+		return err
+	}
+
+	err = page.Navigate(item.URL)
+	if err != nil {
+		return fsfError(fmt.Sprintf("could not open %q", name), err)
+	}
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return fsfError(fmt.Sprintf("%q did not load", name), err)
+	}
+	return nil
 }
 
-func (f *FSF) DownloadDetailedActivityReport(startDate, endDate time.Time, divisions []string, format string) ([]byte, error) {
-	item, err := f.Item("Detailed Activity List")
+// selectPeriod selects the fiscal year and month on the form.
+func (f *FSF) selectPeriod(ctx context.Context, year int, month int) error {
+	page := f.page.Context(ctx)
+	yearSelect, err := page.Element(`select[name="ddlFiscalYear"]`)
 	if err != nil {
-		return nil, err
+		return fsfError("fiscal year list not found", err)
+	}
+	err = yearSelect.Select([]string{fmt.Sprintf("%d", year)}, true, rod.SelectorTypeText)
+	if err != nil {
+		return fsfError(fmt.Sprintf("fiscal year %d missing", year), err)
 	}
 
-	f.page.MustNavigate(item.URL)
-	f.page.MustWaitStable()
+	monthSelect, err := page.Element(`select[name="ddlFiscalMonth"]`)
+	if err != nil {
+		return fsfError("fiscal month list not found", err)
+	}
+	err = monthSelect.Select([]string{time.Month(month).String()}, true, rod.SelectorTypeText)
+	if err != nil {
+		return fsfError(fmt.Sprintf("fiscal month %s missing", time.Month(month)), err)
+	}
+	return nil
+}
 
-	if divisions != nil {
-		divisionMap := map[string]bool{}
-		for _, division := range divisions {
-			divisionMap[division] = true
-		}
+// selectDivisions checks the boxes for the given divisions and unchecks the rest.
+//
+// If the divisions are nil, then the boxes are left alone.
+func (f *FSF) selectDivisions(ctx context.Context, divisions []string) error {
+	if divisions == nil {
+		return nil
+	}
+	page := f.page.Context(ctx)
 
-		divisionInputs := f.page.MustElements(`#cblDivision input[type="checkbox"]`)
-		for _, divisionInput := range divisionInputs {
-			var division string
-			{
-				labelElement := divisionInput.MustParent().MustElement(`label`)
-				label := labelElement.MustText()
-				parts := strings.Split(label, " ")
-				division = parts[0]
-			}
+	divisionMap := map[string]bool{}
+	for _, division := range divisions {
+		divisionMap[division] = true
+	}
 
-			var checked bool
-			{
-				checkedValue := divisionInput.MustAttribute("checked")
-				if checkedValue != nil {
-					checked = *checkedValue == "checked"
-				}
+	divisionInputs, err := page.Elements(`#cblDivision input[type="checkbox"]`)
+	if err != nil {
+		return fsfError("could not list the divisions", err)
+	}
+	for _, divisionInput := range divisionInputs {
+		var division string
+		{
+			parent, err := divisionInput.Parent()
+			if err != nil {
+				return fsfError("division label not found", err)
+			}
+			labelElement, err := parent.Element(`label`)
+			if err != nil {
+				return fsfError("division label not found", err)
+			}
+			label, err := labelElement.Text()
+			if err != nil {
+				return fsfError("could not read a division label", err)
 			}
+			parts := strings.Split(label, " ")
+			division = parts[0]
+		}
+
+		checked, err := isChecked(divisionInput)
+		if err != nil {
+			return fsfError(fmt.Sprintf("could not read the checkbox for division %s", division), err)
+		}
 
-			if divisionMap[division] != checked {
-				divisionInput.MustClick()
+		if divisionMap[division] != checked {
+			err = divisionInput.Click(proto.InputMouseButtonLeft, 1)
+			if err != nil {
+				return fsfError(fmt.Sprintf("could not click the checkbox for division %s", division), err)
 			}
 		}
 	}
-	fmt.Printf("Start date: %v\n", startDate)
-	fmt.Printf("End date: %v\n", endDate)
+	return nil
+}
 
+// enterDate replaces the value of the date input with the given name.
+func (f *FSF) enterDate(ctx context.Context, name string, date time.Time) error {
+	page := f.page.Context(ctx)
 	// The date inputs are weird; they tend to auto-select and move around when you try to mess with them.
 	// We're going to backspace everything and then try to delete everything, and then we can enter the values.
 
-	f.page.MustElement(`input[name="dbxAccountingDateStart"]`).MustClick()
-	f.page.MustElement(`input[name="dbxAccountingDateStart"]`).Type(slices.Repeat([]input.Key{input.Backspace}, 30)...)
-	f.page.MustElement(`input[name="dbxAccountingDateStart"]`).Type(slices.Repeat([]input.Key{input.Delete}, 30)...)
-	f.page.MustElement(`input[name="dbxAccountingDateStart"]`).MustInput(startDate.Format("1/2/2006"))
-
-	f.page.MustElement(`input[name="dbxAccountingDateEnd"]`).MustClick()
-	f.page.MustElement(`input[name="dbxAccountingDateEnd"]`).Type(slices.Repeat([]input.Key{input.Backspace}, 30)...)
-	f.page.MustElement(`input[name="dbxAccountingDateEnd"]`).Type(slices.Repeat([]input.Key{input.Delete}, 30)...)
-	f.page.MustElement(`input[name="dbxAccountingDateEnd"]`).MustInput(endDate.Format("1/2/2006"))
-
-	{
-		input := f.page.MustElement(`input#cbBudgetRefAll`)
-		var checked bool
-		{
-			checkedValue := input.MustAttribute("checked")
-			if checkedValue != nil {
-				checked = *checkedValue == "checked"
-			}
-		}
+	dateInput, err := page.Element(fmt.Sprintf(`input[name="%s"]`, name))
+	if err != nil {
+		return fsfError(fmt.Sprintf("date input %s not found", name), err)
+	}
+	err = dateInput.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return fsfError(fmt.Sprintf("could not click date input %s", name), err)
+	}
+	err = dateInput.Type(slices.Repeat([]input.Key{input.Backspace}, 30)...)
+	if err != nil {
+		return fsfError(fmt.Sprintf("could not clear date input %s", name), err)
+	}
+	err = dateInput.Type(slices.Repeat([]input.Key{input.Delete}, 30)...)
+	if err != nil {
+		return fsfError(fmt.Sprintf("could not clear date input %s", name), err)
+	}
+	err = dateInput.Input(date.Format("1/2/2006"))
+	if err != nil {
+		return fsfError(fmt.Sprintf("could not enter date input %s", name), err)
+	}
+	return nil
+}
 
-		if !checked {
-			input.MustClick()
-		}
+// selectFormat selects the first output format whose name contains the given text, such as "csv".
+func (f *FSF) selectFormat(ctx context.Context, format string) error {
+	page := f.page.Context(ctx)
+	formatElement, err := page.Element(`select[name="ddlFormat"]`)
+	if err != nil {
+		return fsfError("format list not found", err)
 	}
-	formatElement := f.page.MustElement(`select[name="ddlFormat"]`)
-	{
-		selected := false
-		formatOptions := formatElement.MustElements(`option`)
-		for _, formatOption := range formatOptions {
-			if strings.Contains(strings.ToLower(formatOption.MustText()), strings.ToLower(format)) {
-				fmt.Printf("Found the format: %s\n", formatOption.MustText())
-				formatElement.MustSelect(formatOption.MustText())
-				selected = true
-				break
-			}
+	formatOptions, err := formatElement.Elements(`option`)
+	if err != nil {
+		return fsfError("could not list the formats", err)
+	}
+	for _, formatOption := range formatOptions {
+		text, err := formatOption.Text()
+		if err != nil {
+			return fsfError("could not read a format option", err)
 		}
-		if !selected {
-			return nil, fmt.Errorf("format not found")
+		if strings.Contains(strings.ToLower(text), strings.ToLower(format)) {
+			fmt.Printf("Found the format: %s\n", text)
+			err = formatElement.Select([]string{text}, true, rod.SelectorTypeText)
+			if err != nil {
+				return fsfError(fmt.Sprintf("could not select format %q", text), err)
+			}
+			return nil
 		}
 	}
+	return fsfError(fmt.Sprintf("format option %q missing", format), nil)
+}
 
+// download submits the form and returns the file that comes back.
+func (f *FSF) download(ctx context.Context) ([]byte, error) {
+	page := f.page.Context(ctx)
 	fmt.Printf("Waiting for download.\n")
-	download := f.page.Browser().MustWaitDownload()
+	download := scrape.WaitDownload(ctx, page.Browser())
 
-	f.page.MustElement(`input[type="submit"]`).MustClick()
+	submitButton, err := page.Element(`input[type="submit"]`)
+	if err != nil {
+		return nil, fsfError("submit button not found", err)
+	}
+	err = submitButton.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return nil, fsfError("could not click the submit button", err)
+	}
 
 	fmt.Printf("Downloading...\n")
-	contents := download()
+	contents, err := download()
+	if err != nil {
+		return nil, fsfError("could not download the report", err)
+	}
 	fmt.Printf("Downloaded %d bytes.\n", len(contents))
 
 	return contents, nil
 }
 
-// TODO: Total Expenditure Report
-
-// TODO: District Revenue Report
+// isChecked returns whether the checkbox is checked.
+func isChecked(element *rod.Element) (bool, error) {
+	checkedValue, err := element.Attribute("checked")
+	if err != nil {
+		return false, err
+	}
+	return checkedValue != nil && *checkedValue == "checked", nil
+}
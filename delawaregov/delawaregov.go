@@ -1,10 +1,14 @@
 package delawaregov
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/tekkamanendless/cboc-tools/erp"
+	"github.com/tekkamanendless/cboc-tools/scrape"
 )
 
 type DelawareGov struct {
@@ -18,27 +22,67 @@ func New(browser *rod.Browser) *DelawareGov {
 	}
 }
 
-func (g *DelawareGov) Login(username string, password string) error {
+// stepError returns the error for a step that failed.
+func stepError(step string, err error) error {
+	return &scrape.StepError{Site: "delaware.gov", Step: step, Err: err}
+}
+
+func (g *DelawareGov) Login(ctx context.Context, username string, password string) error {
 	fmt.Printf("Logging in to Delaware.gov...\n")
 
 	// Create a new page
-	page := g.browser.MustPage("https://id.delaware.gov").MustWaitStable()
+	page, err := g.browser.Context(ctx).Page(proto.TargetCreateTarget{URL: "https://id.delaware.gov"})
+	if err != nil {
+		return stepError("could not open the login page", err)
+	}
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return stepError("login page did not load", err)
+	}
 
-	formElement := page.MustElement(`form`) // Could be "#form19"
+	formElement, err := page.Element(`form`) // Could be "#form19"
+	if err != nil {
+		return stepError("login form not found", err)
+	}
 
-	usernameInput := formElement.MustElement(`input[autocomplete="username"]`)
-	usernameInput.MustInput(username)
+	usernameInput, err := formElement.Element(`input[autocomplete="username"]`)
+	if err != nil {
+		return stepError("username input not found", err)
+	}
+	err = usernameInput.Input(username)
+	if err != nil {
+		return stepError("could not enter the username", err)
+	}
 
-	passwordInput := formElement.MustElement(`input[type="password"]`)
-	passwordInput.MustInput(password)
+	passwordInput, err := formElement.Element(`input[type="password"]`)
+	if err != nil {
+		return stepError("password input not found", err)
+	}
+	err = passwordInput.Input(password)
+	if err != nil {
+		return stepError("could not enter the password", err)
+	}
 
-	signinButton := formElement.MustElement(`input[type="submit"]`)
-	signinButton.MustClick()
+	signinButton, err := formElement.Element(`input[type="submit"]`)
+	if err != nil {
+		return stepError("sign-in button not found", err)
+	}
+	err = signinButton.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return stepError("could not click the sign-in button", err)
+	}
 
-	page.MustWaitStable()
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return stepError("page after signing in did not load", err)
+	}
 
-	if page.MustInfo().URL == "https://id.delaware.gov/app/UserHome" {
-		return fmt.Errorf("could not log in")
+	info, err := page.Info()
+	if err != nil {
+		return stepError("could not get the page after signing in", err)
+	}
+	if info.URL == "https://id.delaware.gov/app/UserHome" {
+		return stepError("could not log in", nil)
 	}
 
 	g.page = page
@@ -46,10 +90,12 @@ func (g *DelawareGov) Login(username string, password string) error {
 	return nil
 }
 
-func (g *DelawareGov) ERP() (*erp.ERP, error) {
+func (g *DelawareGov) ERP(ctx context.Context) (*erp.ERP, error) {
 	if g.page == nil {
-		return nil, fmt.Errorf("not logged in")
+		return nil, stepError("not logged in", nil)
 	}
+	// The page was opened with the login's context, which may be done by now.
+	g.page = g.page.Context(ctx)
 
 	return erp.New(g.browser), nil
 }
@@ -1,11 +1,14 @@
 package erp
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/tekkamanendless/cboc-tools/mobius"
+	"github.com/tekkamanendless/cboc-tools/scrape"
 )
 
 type ERP struct {
@@ -19,63 +22,140 @@ func New(browser *rod.Browser) *ERP {
 	}
 }
 
-func (e *ERP) Login(username string, password string) error {
+// stepError returns the error for a step that failed.
+func stepError(step string, err error) error {
+	return &scrape.StepError{Site: "erp", Step: step, Err: err}
+}
+
+func (e *ERP) Login(ctx context.Context, username string, password string) error {
 	fmt.Printf("Logging in to the ERP portal...\n")
 
-	page := e.browser.MustPage("https://portal.erp.state.de.us") //.MustWaitStable()
-	time.Sleep(2 * time.Second)
+	page, err := e.browser.Context(ctx).Page(proto.TargetCreateTarget{URL: "https://portal.erp.state.de.us"})
+	if err != nil {
+		return stepError("could not open the login page", err)
+	}
+	err = scrape.Sleep(ctx, 2*time.Second)
+	if err != nil {
+		return stepError("login page did not load", err)
+	}
 
-	formElement := page.MustElement(`form[name="login"]`)
+	formElement, err := page.Element(`form[name="login"]`)
+	if err != nil {
+		return stepError("login form not found", err)
+	}
 
-	usernameInput := formElement.MustElement(`input[name="userid"]`)
-	usernameInput.MustInput(username)
+	usernameInput, err := formElement.Element(`input[name="userid"]`)
+	if err != nil {
+		return stepError("username input not found", err)
+	}
+	err = usernameInput.Input(username)
+	if err != nil {
+		return stepError("could not enter the username", err)
+	}
 
-	passwordInput := formElement.MustElement(`input[type="password"]`)
-	passwordInput.MustInput(password)
+	passwordInput, err := formElement.Element(`input[type="password"]`)
+	if err != nil {
+		return stepError("password input not found", err)
+	}
+	err = passwordInput.Input(password)
+	if err != nil {
+		return stepError("could not enter the password", err)
+	}
 
-	agreeInput := formElement.MustElement(`input[name="agree"]`)
-	agreeInput.MustClick()
+	agreeInput, err := formElement.Element(`input[name="agree"]`)
+	if err != nil {
+		return stepError("agreement checkbox not found", err)
+	}
+	err = agreeInput.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return stepError("could not click the agreement checkbox", err)
+	}
 
-	signinButton := formElement.MustElement(`input[type="submit"]`)
-	signinButton.MustClick()
+	signinButton, err := formElement.Element(`input[type="submit"]`)
+	if err != nil {
+		return stepError("sign-in button not found", err)
+	}
+	err = signinButton.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return stepError("could not click the sign-in button", err)
+	}
 
-	page.MustWaitStable()
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return stepError("page after signing in did not load", err)
+	}
 
 	e.page = page
 
 	return nil
 }
 
-func (e *ERP) Mobius() (*mobius.Mobius, error) {
+func (e *ERP) Mobius(ctx context.Context) (*mobius.Mobius, error) {
 	if e.page == nil {
-		return nil, fmt.Errorf("not logged in")
+		return nil, stepError("not logged in", nil)
 	}
 
 	// TODO: Should we navigate to the main page again first?
 
+	elements, err := e.page.Context(ctx).Elements(".ps_groupleth")
+	if err != nil {
+		return nil, stepError("could not list the portal links", err)
+	}
 	var mobiusLinkElement *rod.Element
-	for _, element := range e.page.MustElements(".ps_groupleth") {
-		if element.MustText() == "Mobius View" {
+	for _, element := range elements {
+		text, err := element.Text()
+		if err != nil {
+			return nil, stepError("could not read a portal link", err)
+		}
+		if text == "Mobius View" {
 			mobiusLinkElement = element
 			break
 		}
 	}
 	if mobiusLinkElement == nil {
-		return nil, fmt.Errorf("could not find Mobius View")
+		return nil, stepError("Mobius View link not found", nil)
+	}
+	err = mobiusLinkElement.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return nil, stepError("could not click the Mobius View link", err)
+	}
+	err = scrape.Sleep(ctx, 2*time.Second)
+	if err != nil {
+		return nil, stepError("Mobius View did not open", err)
 	}
-	mobiusLinkElement.MustClick()
-	time.Sleep(2 * time.Second)
 
-	pages := e.browser.MustPages()
+	pages, err := e.browser.Context(ctx).Pages()
+	if err != nil {
+		return nil, stepError("could not list the browser pages", err)
+	}
 	for _, page := range pages {
-		fmt.Printf("page: %s\n", page.MustInfo().URL)
+		info, err := page.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Printf("page: %s\n", info.URL)
 	}
 
-	page := pages.MustFindByURL("viewerpreports.dti")
-	fmt.Printf("page: %s\n", page.MustInfo().URL)
-	page.MustElement("button#continue").MustClick()
+	page, err := pages.FindByURL("viewerpreports.dti")
+	if err != nil {
+		return nil, stepError("Mobius page not found", err)
+	}
+	if info, err := page.Info(); err == nil {
+		fmt.Printf("page: %s\n", info.URL)
+	}
+	continueButton, err := page.Element("button#continue")
+	if err != nil {
+		return nil, stepError("Mobius continue button not found", err)
+	}
+	err = continueButton.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return nil, stepError("could not click the Mobius continue button", err)
+	}
 
-	page.MustWaitStable()
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return nil, stepError("Mobius page did not load", err)
+	}
 	page.WaitDOMStable(5*time.Second, 10)
 
 	e.page = page
@@ -0,0 +1,440 @@
+// Package fsfreport reads the FSF summary reports from the PDFs that the "report" command saves next to the CSVs.
+//
+// A PDF has no rows or columns, only text drawn at a position on the page, so the text is first put back together
+// into lines (by its height on the page) and cells (by the gaps between the characters).  The column headings are the
+// first line on each page that has enough of the headings that the report should have; the headings may be stacked
+// over two lines, such as "Budget" over "Amount".  Each cell below that belongs to the heading that it sits under.
+//
+// Lines with the word "Total" are subtotals, except for the grand total, which is used to check that every row was
+// read: the amounts of the rows must add up to the amounts printed on the grand total line.
+//
+// The values are handed to the csvloader package keyed by the column names of the CSV export, so a row from a PDF is
+// loaded into exactly the same model as a row from the CSV.
+package fsfreport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+	"github.com/tekkamanendless/cboc-tools/csvloader"
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// Result is everything that was read from a report.
+type Result[T any] struct {
+	Records    []T
+	Lines      []int              // This is the line of the document (counting from the first page) that each record came from.
+	Rejects    []csvloader.Reject // These are the rows that could not be loaded.
+	Subtotals  int                // This is the number of subtotal lines that were skipped.
+	GrandTotal T                  // These are the amounts from the grand total line.
+}
+
+// ErrWrongReport means that the file is not the kind of report that it was parsed as.
+var ErrWrongReport = errors.New("wrong report")
+
+// ErrIncomplete means that the file is the right kind of report, but that its rows do not match its grand total.
+var ErrIncomplete = errors.New("incomplete report")
+
+// layout describes one of the reports.
+type layout struct {
+	name     string
+	columns  map[string]string // This maps the (normalized) column heading to the column in the CSV export.
+	amounts  []string          // These are the columns (in the CSV export) that hold amounts; a line with any of these is a row.
+	context  []contextPattern
+	minMatch int      // This is how many headings a line needs to be taken as the heading line.
+	required []string // These are the columns (in the CSV export) that tell this report apart from the others.
+}
+
+// contextPattern pulls a value out of the page headers or the group headings.
+//
+// Each group in the pattern is saved as the matching column in the CSV export.
+type contextPattern struct {
+	pattern *regexp.Regexp
+	columns []string
+}
+
+var (
+	districtPattern    = regexp.MustCompile(`(?i)\bdistrict:\s*(.*?)\s*$`)
+	divisionPattern    = regexp.MustCompile(`(?i)\bdiv(?:ision)?:?\s+(\d+)\b`)
+	fiscalYearPattern  = regexp.MustCompile(`(?i)\bfiscal year:?\s+(\d{2,4})\b`)
+	fiscalMonthPattern = regexp.MustCompile(`(?i)\b(?:fiscal month|accounting period|period):?\s+(\d{1,2}|january|february|march|april|may|june|july|august|september|october|november|december)\b`)
+	pagePattern        = regexp.MustCompile(`(?i)\bpage\s+\d+(\s+of\s+\d+)?\b`)
+	subtotalPattern    = regexp.MustCompile(`(?i)\btotals?\b`)
+	grandTotalPattern  = regexp.MustCompile(`(?i)\b(grand|report)\s+totals?\b`)
+)
+
+// cell is a run of text on a line.
+type cell struct {
+	text  string
+	left  float64
+	right float64
+}
+
+// line is the text at one height on a page.
+type line struct {
+	page     int
+	y        float64
+	fontSize float64
+	cells    []cell
+}
+
+// String returns the text of the line, with a wide gap between the cells.
+func (l line) String() string {
+	var parts []string
+	for _, c := range l.cells {
+		parts = append(parts, c.text)
+	}
+	return strings.Join(parts, "   ")
+}
+
+// column is where a column sits on the page.
+type column struct {
+	name  string // This is the column in the CSV export.
+	left  float64
+	right float64
+}
+
+// parse reads the report one line at a time.
+func parse[T any](r io.ReaderAt, size int64, l layout, amounts func(*T) []databasemodel.Money) (*Result[T], error) {
+	lines, err := readLines(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result[T]{}
+	context := map[string]string{}
+	var columns []column
+	var foundHeadings bool
+	var foundGrandTotal bool
+	var sums []databasemodel.Money
+
+	// A row is only finished once the next line is known not to be more of its text, such as a long description.
+	var pending map[string]string
+	var pendingLine int
+	var previous *line
+	flush := func() error {
+		if pending == nil {
+			return nil
+		}
+		record, reject, err := csvloader.Decode[T](pending)
+		pending = nil
+		if err != nil {
+			return err
+		}
+		if reject != nil {
+			reject.Line = pendingLine
+			result.Rejects = append(result.Rejects, *reject)
+			return nil
+		}
+		for i, amount := range amounts(&record) {
+			if i >= len(sums) {
+				sums = append(sums, 0)
+			}
+			sums[i] += amount
+		}
+		result.Records = append(result.Records, record)
+		result.Lines = append(result.Lines, pendingLine)
+		return nil
+	}
+
+	currentPage := 0
+	for i := range lines {
+		current := &lines[i]
+		lineNumber := i + 1
+
+		// The headings are printed again at the top of every page, and the rows do not start again until after them.
+		if current.page != currentPage {
+			currentPage = current.page
+			columns = nil
+			previous = nil
+		}
+		if headings := findHeadings(lines, i, l); headings != nil {
+			columns = headings
+			foundHeadings = true
+			previous = nil
+			continue
+		}
+
+		values := map[string]string{}
+		for _, c := range current.cells {
+			name := columnFor(c, columns)
+			if name == "" {
+				continue
+			}
+			if values[name] != "" {
+				values[name] += " "
+			}
+			values[name] += c.text
+		}
+		hasAmount := false
+		for _, name := range l.amounts {
+			if values[name] != "" {
+				hasAmount = true
+			}
+		}
+
+		if !hasAmount {
+			text := current.String()
+			switch {
+			case pagePattern.MatchString(text):
+				// This is a page header or footer.
+			case isContext(text, l):
+				err := flush()
+				if err != nil {
+					return nil, err
+				}
+				for _, c := range l.context {
+					match := c.pattern.FindStringSubmatch(text)
+					if match == nil {
+						continue
+					}
+					for g, name := range c.columns {
+						context[name] = strings.TrimSpace(match[g+1])
+					}
+				}
+				previous = nil
+			case pending != nil && previous != nil && current.y > previous.y-1.5*current.fontSize:
+				// This is more of the text of the row above, such as a description that wrapped.
+				for name, value := range values {
+					if pending[name] != "" {
+						pending[name] += " "
+					}
+					pending[name] += value
+				}
+				previous = current
+			}
+			continue
+		}
+		err := flush()
+		if err != nil {
+			return nil, err
+		}
+		previous = nil
+
+		text := current.String()
+		if grandTotalPattern.MatchString(text) {
+			values := valuesOnly(values, l.amounts)
+			record, reject, err := csvloader.Decode[T](values)
+			if err != nil {
+				return nil, err
+			}
+			if reject != nil {
+				reject.Line = lineNumber
+				return nil, fmt.Errorf("could not read the grand total: %w: %w", reject, ErrIncomplete)
+			}
+			result.GrandTotal = record
+			foundGrandTotal = true
+			continue
+		}
+		if subtotalPattern.MatchString(text) {
+			result.Subtotals++
+			continue
+		}
+
+		pending = map[string]string{}
+		for name, value := range context {
+			pending[name] = value
+		}
+		for name, value := range values {
+			pending[name] = value
+		}
+		pendingLine = lineNumber
+		previous = current
+	}
+	err = flush()
+	if err != nil {
+		return nil, err
+	}
+
+	if !foundHeadings {
+		return nil, fmt.Errorf("this is not a %s report; could not find the column headings: %w", l.name, ErrWrongReport)
+	}
+	if !foundGrandTotal {
+		return nil, fmt.Errorf("could not find the grand total: %w", ErrIncomplete)
+	}
+	for i, total := range amounts(&result.GrandTotal) {
+		var sum databasemodel.Money
+		if i < len(sums) {
+			sum = sums[i]
+		}
+		if sum != total {
+			return nil, fmt.Errorf("the rows add up to %s for %s, but the grand total is %s (%d rows rejected): %w", sum, l.amounts[i], total, len(result.Rejects), ErrIncomplete)
+		}
+	}
+	return result, nil
+}
+
+// valuesOnly returns just the given columns from the values.
+func valuesOnly(values map[string]string, names []string) map[string]string {
+	output := map[string]string{}
+	for _, name := range names {
+		output[name] = values[name]
+	}
+	return output
+}
+
+// isContext returns true if the line sets the context for the rows that follow.
+func isContext(text string, l layout) bool {
+	for _, c := range l.context {
+		if c.pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// findHeadings returns the columns if the given line is the heading line.
+//
+// The headings may be stacked over two lines, in which case the given line is the bottom one.
+func findHeadings(lines []line, index int, l layout) []column {
+	current := lines[index]
+	var above *line
+	if index > 0 && lines[index-1].page == current.page && lines[index-1].y < current.y+2.5*current.fontSize {
+		above = &lines[index-1]
+	}
+
+	var columns []column
+	for _, c := range current.cells {
+		heading := c.text
+		left, right := c.left, c.right
+		if above != nil {
+			for _, a := range above.cells {
+				if a.right < c.left || a.left > c.right {
+					continue
+				}
+				heading = a.text + " " + heading
+				left = math.Min(left, a.left)
+				right = math.Max(right, a.right)
+			}
+		}
+		heading = strings.Join(strings.Fields(strings.ToUpper(heading)), " ")
+		name, ok := l.columns[heading]
+		if !ok {
+			// A stacked heading could also just be a heading on its own.
+			name, ok = l.columns[strings.Join(strings.Fields(strings.ToUpper(c.text)), " ")]
+			if !ok {
+				continue
+			}
+			left, right = c.left, c.right
+		}
+		columns = append(columns, column{name: name, left: left, right: right})
+	}
+	if len(columns) < l.minMatch {
+		return nil
+	}
+	for _, name := range l.required {
+		if !slices.ContainsFunc(columns, func(c column) bool { return c.name == name }) {
+			return nil
+		}
+	}
+	return columns
+}
+
+// columnFor returns the column that the cell sits under.
+//
+// Text is usually left-aligned and amounts are right-aligned, so a cell may stick out past its heading; the column
+// that it overlaps the most wins, and if it does not overlap any, then the nearest one does.
+func columnFor(c cell, columns []column) string {
+	var best string
+	var bestOverlap float64
+	for _, col := range columns {
+		overlap := math.Min(c.right, col.right) - math.Max(c.left, col.left)
+		if overlap > bestOverlap {
+			best = col.name
+			bestOverlap = overlap
+		}
+	}
+	if best != "" {
+		return best
+	}
+	bestDistance := math.Inf(1)
+	center := (c.left + c.right) / 2
+	for _, col := range columns {
+		distance := math.Abs(center - (col.left+col.right)/2)
+		if distance < bestDistance {
+			best = col.name
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+// readLines pulls the text out of every page of the PDF.
+//
+// The PDF library panics on content that it does not understand, so that is turned into an error.
+func readLines(r io.ReaderAt, size int64) (lines []line, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			lines = nil
+			err = fmt.Errorf("could not read PDF: %v", recovered)
+		}
+	}()
+
+	reader, err := pdf.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("could not read PDF: %w", err)
+	}
+	for pageNumber := 1; pageNumber <= reader.NumPage(); pageNumber++ {
+		page := reader.Page(pageNumber)
+		if page.V.IsNull() {
+			continue
+		}
+		lines = append(lines, pageLines(pageNumber, page.Content().Text)...)
+	}
+	return lines, nil
+}
+
+// pageLines groups the characters on a page into lines, from the top of the page to the bottom, and then into cells.
+func pageLines(pageNumber int, texts []pdf.Text) []line {
+	var characters []pdf.Text
+	for _, t := range texts {
+		if strings.TrimFunc(t.S, func(r rune) bool { return r < ' ' }) == "" {
+			continue
+		}
+		characters = append(characters, t)
+	}
+	sort.SliceStable(characters, func(i, j int) bool {
+		if math.Abs(characters[i].Y-characters[j].Y) > 1 {
+			return characters[i].Y > characters[j].Y
+		}
+		return characters[i].X < characters[j].X
+	})
+
+	var lines []line
+	for _, t := range characters {
+		if len(lines) == 0 || math.Abs(lines[len(lines)-1].y-t.Y) > 1 {
+			lines = append(lines, line{page: pageNumber, y: t.Y, fontSize: t.FontSize})
+		}
+		current := &lines[len(lines)-1]
+
+		width := t.W
+		if width <= 0 {
+			width = t.FontSize / 2 // Not every font says how wide its characters are.
+		}
+		// A gap of about two spaces or more is the start of a new cell.
+		if len(current.cells) == 0 || t.X-current.cells[len(current.cells)-1].right > t.FontSize {
+			current.cells = append(current.cells, cell{left: t.X, right: t.X})
+		}
+		c := &current.cells[len(current.cells)-1]
+		c.text += t.S
+		c.right = t.X + width
+	}
+	for l := range lines {
+		var cells []cell
+		for _, c := range lines[l].cells {
+			c.text = strings.TrimSpace(c.text)
+			if c.text != "" {
+				cells = append(cells, c)
+			}
+		}
+		lines[l].cells = cells
+	}
+	return lines
+}
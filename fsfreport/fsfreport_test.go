@@ -0,0 +1,131 @@
+package fsfreport
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// openTestFile opens a file from the testdata directory.
+func openTestFile(t *testing.T, name string) (*os.File, int64) {
+	t.Helper()
+
+	fileHandle, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Could not open %s: %v", name, err)
+	}
+	t.Cleanup(func() { fileHandle.Close() })
+	info, err := fileHandle.Stat()
+	if err != nil {
+		t.Fatalf("Could not stat %s: %v", name, err)
+	}
+	return fileHandle, info.Size()
+}
+
+func TestParseOperatingUnitExpenditureSummary(t *testing.T) {
+	fileHandle, size := openTestFile(t, "fsf.operating-unit-expenditure-summary.pdf")
+	result, err := ParseOperatingUnitExpenditureSummary(fileHandle, size)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []databasemodel.FSFOperatingUnitExpenditureSummary{
+		{District: "Christina School District", Division: "33", RecordType: "D", SubType: "X", OperatingUnit: "1000", OperatingUnitDescription: "Admin", BudgetedAmount: 120000, EncumberedAmount: 25000, ExpendedAmount: 40000},
+		// The description wraps onto a second line.
+		{District: "Christina School District", Division: "33", RecordType: "D", SubType: "X", OperatingUnit: "1100", OperatingUnitDescription: "Instructional Support and Curriculum Development", BudgetedAmount: 300000, EncumberedAmount: 0, ExpendedAmount: -5000},
+		// The division heading on the second page applies to the rows below it.
+		{District: "Christina School District", Division: "51", RecordType: "D", SubType: "X", OperatingUnit: "2000", OperatingUnitDescription: "Ops", BudgetedAmount: 50000, EncumberedAmount: 0, ExpendedAmount: 10000},
+	}
+	if len(result.Records) != len(expected) {
+		t.Fatalf("Expected %d records; got %d: %+v", len(expected), len(result.Records), result.Records)
+	}
+	for i, record := range result.Records {
+		// Every row gets the period from the page header.
+		if record.FiscalYear != 2025 || record.FiscalMonth != "3" {
+			t.Errorf("Record %d: expected fiscal year 2025, period 3; got %d, %q.", i, record.FiscalYear, record.FiscalMonth)
+		}
+		record.FiscalYear, record.FiscalMonth = 0, ""
+		if record != expected[i] {
+			t.Errorf("Record %d:\nexpected %+v\n     got %+v", i, expected[i], record)
+		}
+	}
+	if len(result.Rejects) != 0 {
+		t.Errorf("Expected no rejects; got %+v", result.Rejects)
+	}
+	if result.Subtotals != 2 {
+		t.Errorf("Expected 2 subtotals; got %d.", result.Subtotals)
+	}
+	if result.GrandTotal.BudgetedAmount != 470000 || result.GrandTotal.EncumberedAmount != 25000 || result.GrandTotal.ExpendedAmount != 45000 {
+		t.Errorf("Wrong grand total: %+v", result.GrandTotal)
+	}
+}
+
+func TestParseOperatingUnitProgramSummary(t *testing.T) {
+	fileHandle, size := openTestFile(t, "fsf.operating-unit-program-summary.pdf")
+	result, err := ParseOperatingUnitProgramSummary(fileHandle, size)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []databasemodel.FSFOperatingUnitProgramSummary{
+		{District: "Christina School District", Division: "33", OperatingUnit: "1000", OperatingUnitDescription: "Admin", ProgramCode: "P1", ProgramCodeDescription: "Prog one", BudgetedAmount: 100050, EncumberedAmount: 20000, ExpendedAmount: 30000},
+		// A trailing minus sign is a negative amount.
+		{District: "Christina School District", Division: "33", OperatingUnit: "1000", OperatingUnitDescription: "Admin", ProgramCode: "P2", ProgramCodeDescription: "Prog two", BudgetedAmount: 25000, EncumberedAmount: 0, ExpendedAmount: -12525},
+		{District: "Christina School District", Division: "51", OperatingUnit: "2000", OperatingUnitDescription: "Ops", ProgramCode: "P1", ProgramCodeDescription: "Prog one", BudgetedAmount: 7500, EncumberedAmount: 1000, ExpendedAmount: 2000},
+	}
+	if len(result.Records) != len(expected) {
+		t.Fatalf("Expected %d records; got %d: %+v", len(expected), len(result.Records), result.Records)
+	}
+	for i, record := range result.Records {
+		if record.FiscalYear != 2025 || record.FiscalMonth != "3" {
+			t.Errorf("Record %d: expected fiscal year 2025, period 3; got %d, %q.", i, record.FiscalYear, record.FiscalMonth)
+		}
+		record.FiscalYear, record.FiscalMonth = 0, ""
+		if record != expected[i] {
+			t.Errorf("Record %d:\nexpected %+v\n     got %+v", i, expected[i], record)
+		}
+	}
+	if result.Subtotals != 0 {
+		t.Errorf("Expected no subtotals; got %d.", result.Subtotals)
+	}
+	if result.GrandTotal.ExpendedAmount != 19475 {
+		t.Errorf("Wrong grand total: %+v", result.GrandTotal)
+	}
+}
+
+func TestParseWrongReport(t *testing.T) {
+	rows := []struct {
+		name  string
+		parse func(*os.File, int64) error
+	}{
+		{"fsf.operating-unit-expenditure-summary.pdf", func(f *os.File, size int64) error {
+			_, err := ParseOperatingUnitProgramSummary(f, size)
+			return err
+		}},
+		{"fsf.operating-unit-program-summary.pdf", func(f *os.File, size int64) error {
+			_, err := ParseOperatingUnitExpenditureSummary(f, size)
+			return err
+		}},
+	}
+	for _, row := range rows {
+		fileHandle, size := openTestFile(t, row.name)
+		err := row.parse(fileHandle, size)
+		if !errors.Is(err, ErrWrongReport) {
+			t.Errorf("%s: expected ErrWrongReport; got %v", row.name, err)
+		}
+	}
+}
+
+func TestParseNotAPDF(t *testing.T) {
+	contents := "%PDF-1.4\nnot really a PDF\n"
+	_, err := ParseOperatingUnitExpenditureSummary(strings.NewReader(contents), int64(len(contents)))
+	if err == nil {
+		t.Fatalf("Expected an error.")
+	}
+	if errors.Is(err, ErrWrongReport) || errors.Is(err, ErrIncomplete) {
+		t.Errorf("Expected a read error; got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package fsfreport
+
+import (
+	"io"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// amountHeadings are the ways that the amount columns are labeled in both reports.
+var amountHeadings = map[string]string{
+	"BUDGET":            "budgetamt",
+	"BUDGET AMOUNT":     "budgetamt",
+	"BUDGETED AMOUNT":   "budgetamt",
+	"ENCUMBERED":        "encumberedamt",
+	"ENCUMBERED AMOUNT": "encumberedamt",
+	"ENCUMBRANCES":      "encumberedamt",
+	"EXPENDED":          "expendedamt",
+	"EXPENDED AMOUNT":   "expendedamt",
+	"EXPENDITURES":      "expendedamt",
+}
+
+// summaryContext is what the page headers of both reports have.
+var summaryContext = []contextPattern{
+	{pattern: districtPattern, columns: []string{"district"}},
+	{pattern: divisionPattern, columns: []string{"div"}},
+	{pattern: fiscalYearPattern, columns: []string{"fiscalyear"}},
+	{pattern: fiscalMonthPattern, columns: []string{"fiscalmonth"}},
+}
+
+// withAmounts adds the amount headings to the given headings.
+func withAmounts(columns map[string]string) map[string]string {
+	for heading, name := range amountHeadings {
+		columns[heading] = name
+	}
+	return columns
+}
+
+// expenditureSummaryLayout is the Operating Unit Expenditure Summary report.
+var expenditureSummaryLayout = layout{
+	name: "Operating Unit Expenditure Summary",
+	columns: withAmounts(map[string]string{
+		"DISTRICT":       "district",
+		"DIV":            "div",
+		"DIVISION":       "div",
+		"RECORD TYPE":    "recordtype",
+		"SUB TYPE":       "subtype",
+		"SUBTYPE":        "subtype",
+		"OPERATING UNIT": "operatingunit",
+		"DESCRIPTION":    "descr",
+		"DESCR":          "descr",
+	}),
+	amounts:  []string{"budgetamt", "encumberedamt", "expendedamt"},
+	context:  summaryContext,
+	minMatch: 3,
+	required: []string{"operatingunit", "descr"},
+}
+
+// programSummaryLayout is the Operating Unit Program Summary report.
+var programSummaryLayout = layout{
+	name: "Operating Unit Program Summary",
+	columns: withAmounts(map[string]string{
+		"DISTRICT":                   "district",
+		"DIV":                        "div",
+		"DIVISION":                   "div",
+		"RECORD TYPE":                "recordtype",
+		"OPERATING UNIT":             "operatingunit",
+		"OPERATING UNIT DESCRIPTION": "operatingunitdesc",
+		"PROGRAM CODE":               "programcode",
+		"PROGRAM":                    "programcode",
+		"PROGRAM CODE DESCRIPTION":   "programcodedesc",
+		"PROGRAM DESCRIPTION":        "programcodedesc",
+	}),
+	amounts:  []string{"budgetamt", "encumberedamt", "expendedamt"},
+	context:  summaryContext,
+	minMatch: 4,
+	required: []string{"operatingunit", "programcode"},
+}
+
+// ParseOperatingUnitExpenditureSummary reads the Operating Unit Expenditure Summary PDF.
+func ParseOperatingUnitExpenditureSummary(r io.ReaderAt, size int64) (*Result[databasemodel.FSFOperatingUnitExpenditureSummary], error) {
+	return parse(r, size, expenditureSummaryLayout, func(record *databasemodel.FSFOperatingUnitExpenditureSummary) []databasemodel.Money {
+		return []databasemodel.Money{record.BudgetedAmount, record.EncumberedAmount, record.ExpendedAmount}
+	})
+}
+
+// ParseOperatingUnitProgramSummary reads the Operating Unit Program Summary PDF.
+func ParseOperatingUnitProgramSummary(r io.ReaderAt, size int64) (*Result[databasemodel.FSFOperatingUnitProgramSummary], error) {
+	return parse(r, size, programSummaryLayout, func(record *databasemodel.FSFOperatingUnitProgramSummary) []databasemodel.Money {
+		return []databasemodel.Money{record.BudgetedAmount, record.EncumberedAmount, record.ExpendedAmount}
+	})
+}
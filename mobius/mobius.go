@@ -1,6 +1,7 @@
 package mobius
 
 import (
+	"context"
 	"fmt"
 	"slices"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/input"
 	"github.com/go-rod/rod/lib/proto"
+	"github.com/tekkamanendless/cboc-tools/scrape"
 )
 
 type Mobius struct {
@@ -21,10 +23,20 @@ func New(page *rod.Page) *Mobius {
 	}
 }
 
-func (m *Mobius) GoToReport(path []string) error {
+// stepError returns the error for a step that failed.
+func stepError(step string, err error) error {
+	return &scrape.StepError{Site: "mobius", Step: step, Err: err}
+}
+
+// GoToReport goes to the report with the given path, such as "DGL060" and the date of the report.
+func (m *Mobius) GoToReport(ctx context.Context, path []string) error {
+	page := m.page.Context(ctx)
 	fmt.Printf("GoToReport: %v\n", path)
 
-	breadcrumbs := m.breadcrumbs()
+	breadcrumbs, err := m.breadcrumbs(ctx)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("breadcrumbs: %+v\n", breadcrumbs)
 
 	var lastBreadcrumb *Breadcrumb
@@ -39,7 +51,7 @@ func (m *Mobius) GoToReport(path []string) error {
 		}
 	}
 	if lastBreadcrumb == nil {
-		return fmt.Errorf("could not find a breadcrumb for the path: %v", path)
+		return stepError(fmt.Sprintf("no breadcrumb for the path %v", path), nil)
 	}
 	fmt.Printf("Last breadcrumb: %+v\n", *lastBreadcrumb)
 	fmt.Printf("Remaining path: %v\n", remainingPath)
@@ -53,24 +65,24 @@ func (m *Mobius) GoToReport(path []string) error {
 				fmt.Printf("Could not click: %v\n", err)
 			}
 		} else {
-			m.page.WaitDOMStable(5*time.Second, 10)
+			page.WaitDOMStable(5*time.Second, 10)
 		}
 	}
 
 	{
 		for _, pathPart := range remainingPath {
-			itemMap, err := m.GetItems()
+			itemMap, err := m.GetItems(ctx)
 			if err != nil {
-				return fmt.Errorf("could not get items: %w", err)
+				return err
 			}
 			if _, ok := itemMap[pathPart]; !ok {
-				err := m.SearchItems(pathPart)
+				err := m.SearchItems(ctx, pathPart)
 				if err != nil {
 					return err
 				}
 			}
 
-			err = m.ClickItem(pathPart)
+			err = m.ClickItem(ctx, pathPart)
 			if err != nil {
 				return err
 			}
@@ -80,71 +92,103 @@ func (m *Mobius) GoToReport(path []string) error {
 	return nil
 }
 
-func (m *Mobius) ExtractReport(reportName string, division string) ([]byte, error) {
+// ExtractReport extracts the report for the division from the current report and returns the downloaded file.
+func (m *Mobius) ExtractReport(ctx context.Context, reportName string, division string) ([]byte, error) {
 	fmt.Printf("ExtractReport: reportName=%s division=%s\n", reportName, division)
 
-	itemMap, err := m.GetItems()
+	page := m.page.Context(ctx)
+
+	itemMap, err := m.GetItems(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("could not get items: %w", err)
+		return nil, err
 	}
 	if _, ok := itemMap[division]; !ok {
-		err := m.SearchItems(division)
+		err := m.SearchItems(ctx, division)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err = m.ClickItem(division)
+	err = m.ClickItem(ctx, division)
 	if err != nil {
 		return nil, err
 	}
 
-	page := m.page
-
 	// Extract
-	page.MustElement(`app-mobius-view-docviewer mobius-toolbar div[title="Extract"]`).MustClick()
+	err = clickElement(page, `app-mobius-view-docviewer mobius-toolbar div[title="Extract"]`, "extract button")
+	if err != nil {
+		return nil, err
+	}
 	page.WaitDOMStable(5*time.Second, 10)
 	page.WaitDOMStable(5*time.Second, 10)
 	page.WaitDOMStable(5*time.Second, 10)
 
-	err = m.ClickItem(reportName)
+	err = m.ClickItem(ctx, reportName)
 	if err != nil {
 		return nil, err
 	}
 	page.WaitDOMStable(5*time.Second, 10)
 
-	page.MustElement(`app-mobius-view-extract-results mobius-toolbar div[title="Export"]`).MustClick()
+	err = clickElement(page, `app-mobius-view-extract-results mobius-toolbar div[title="Export"]`, "export button")
+	if err != nil {
+		return nil, err
+	}
 	page.WaitDOMStable(5*time.Second, 10)
 
 	{
-		dontZipElement := page.MustElement(`ngb-modal-window mobius-ui-checkbox#dontZipDownloadFile`)
+		dontZipElement, err := page.Element(`ngb-modal-window mobius-ui-checkbox#dontZipDownloadFile`)
+		if err != nil {
+			return nil, stepError("don't-zip checkbox not found", err)
+		}
 		checked := false
 		{
-			dontZipCheckboxElement := dontZipElement.MustElement(`.basicCheckbox`)
-			classNamesString := dontZipCheckboxElement.MustAttribute("class")
+			dontZipCheckboxElement, err := dontZipElement.Element(`.basicCheckbox`)
+			if err != nil {
+				return nil, stepError("don't-zip checkbox not found", err)
+			}
+			classNamesString, err := dontZipCheckboxElement.Attribute("class")
+			if err != nil {
+				return nil, stepError("could not read the don't-zip checkbox", err)
+			}
 			if classNamesString != nil {
 				classNames := strings.Split(*classNamesString, " ")
 				checked = slices.Contains(classNames, "checked")
 			}
 		}
 		if !checked {
-			dontZipElement.MustElement(`a`).MustClick()
+			linkElement, err := dontZipElement.Element(`a`)
+			if err != nil {
+				return nil, stepError("don't-zip checkbox link not found", err)
+			}
+			err = linkElement.Click(proto.InputMouseButtonLeft, 1)
+			if err != nil {
+				return nil, stepError("could not click the don't-zip checkbox", err)
+			}
 			page.WaitDOMStable(5*time.Second, 10)
 		}
 	}
 
 	fmt.Printf("Waiting for download.\n")
-	download := page.Browser().MustWaitDownload()
+	download := scrape.WaitDownload(ctx, page.Browser())
 
-	page.MustElement(`ngb-modal-window button.btn-submit`).MustClick()
+	err = clickElement(page, `ngb-modal-window button.btn-submit`, "export submit button")
+	if err != nil {
+		return nil, err
+	}
 	page.WaitDOMStable(5*time.Second, 10)
 
 	fmt.Printf("Downloading...\n")
-	contents := download()
+	contents, err := download()
+	if err != nil {
+		return nil, stepError("could not download the report", err)
+	}
 	fmt.Printf("Downloaded %d bytes.\n", len(contents))
 
 	// Close the file preview.
-	page.MustElement(`app-mobius-view-extract-results mobius-ui-dv-close`).MustClick()
+	err = clickElement(page, `app-mobius-view-extract-results mobius-ui-dv-close`, "file preview close button")
+	if err != nil {
+		return nil, err
+	}
 	page.WaitDOMStable(5*time.Second, 10)
 
 	// TODO: Close the file preview?
@@ -169,12 +213,33 @@ func (m *Mobius) ExtractReport(reportName string, division string) ([]byte, erro
 	return contents, nil
 }
 
+// Reload reloads the page, which gets Mobius unstuck when clicking has stopped doing anything.
+//
+// GoToReport has to be called again afterward.
+func (m *Mobius) Reload(ctx context.Context) error {
+	fmt.Printf("Reload\n")
+
+	page := m.page.Context(ctx)
+	err := page.Reload()
+	if err != nil {
+		return stepError("could not reload the page", err)
+	}
+	err = page.WaitStable(time.Second)
+	if err != nil {
+		return stepError("page did not load after reloading", err)
+	}
+	page.WaitDOMStable(5*time.Second, 10)
+	return nil
+}
+
 type Breadcrumb struct {
 	Name    string
 	Element *rod.Element
 }
 
-func (m *Mobius) breadcrumbs() []Breadcrumb {
+func (m *Mobius) breadcrumbs(ctx context.Context) ([]Breadcrumb, error) {
+	page := m.page.Context(ctx)
+
 	/*
 			<a _ngcontent-c40="" class="breadcrumb-item" href="#">
 		                DGL060
@@ -182,34 +247,49 @@ func (m *Mobius) breadcrumbs() []Breadcrumb {
 	*/
 
 	var output []Breadcrumb
-	breadcrumbElement := m.page.MustElement(`mobius-ui-content-breadcrumb`) // Only get the first one.
-	breadcrumbElements := breadcrumbElement.MustElements(`a.breadcrumb-item`)
+	breadcrumbElement, err := page.Element(`mobius-ui-content-breadcrumb`) // Only get the first one.
+	if err != nil {
+		return nil, stepError("breadcrumbs not found", err)
+	}
+	breadcrumbElements, err := breadcrumbElement.Elements(`a.breadcrumb-item`)
+	if err != nil {
+		return nil, stepError("could not list the breadcrumbs", err)
+	}
 	for _, breadcrumbElement := range breadcrumbElements {
+		text, err := breadcrumbElement.Text()
+		if err != nil {
+			return nil, stepError("could not read a breadcrumb", err)
+		}
 		breadcrumb := Breadcrumb{
-			Name:    strings.TrimSpace(breadcrumbElement.MustText()),
+			Name:    strings.TrimSpace(text),
 			Element: breadcrumbElement,
 		}
 		output = append(output, breadcrumb)
 	}
-	return output
+	return output, nil
 }
 
-func (m *Mobius) ClickItem(name string) error {
+func (m *Mobius) ClickItem(ctx context.Context, name string) error {
 	fmt.Printf("ClickItem: %s\n", name)
 
-	itemMap, err := m.GetItems()
+	page := m.page.Context(ctx)
+
+	itemMap, err := m.GetItems(ctx)
 	if err != nil {
 		return err
 	}
 	targetItemElement := itemMap[name]
 	if targetItemElement == nil {
-		return fmt.Errorf("could not find item: %s", name)
+		return stepError(fmt.Sprintf("item %q not found", name), nil)
+	}
+	err = targetItemElement.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return stepError(fmt.Sprintf("could not click item %q", name), err)
 	}
-	targetItemElement.MustClick()
 
-	m.page.WaitDOMStable(5*time.Second, 10)
+	page.WaitDOMStable(5*time.Second, 10)
 
-	itemMap, err = m.GetItems()
+	itemMap, err = m.GetItems(ctx)
 	if err != nil {
 		return err
 	}
@@ -217,31 +297,45 @@ func (m *Mobius) ClickItem(name string) error {
 	if targetItemElement != nil {
 		fmt.Printf("No dice; trying again.\n")
 
-		targetItemElement.MustClick()
+		err = targetItemElement.Click(proto.InputMouseButtonLeft, 1)
+		if err != nil {
+			return stepError(fmt.Sprintf("could not click item %q", name), err)
+		}
 
-		m.page.WaitDOMStable(5*time.Second, 10)
+		page.WaitDOMStable(5*time.Second, 10)
 	}
 
 	return nil
 }
 
-func (m *Mobius) GetItems() (map[string]*rod.Element, error) {
+func (m *Mobius) GetItems(ctx context.Context) (map[string]*rod.Element, error) {
+	page := m.page.Context(ctx)
+
 	output := map[string]*rod.Element{}
-	itemElements := m.page.MustElements("app-mobius-view-content-list mobius-content-list mobius-content-item .content-item-label")
+	itemElements, err := page.Elements("app-mobius-view-content-list mobius-content-list mobius-content-item .content-item-label")
+	if err != nil {
+		return nil, stepError("could not list the items", err)
+	}
 	for _, itemElement := range itemElements {
-		text := strings.TrimSpace(itemElement.MustText())
+		text, err := itemElement.Text()
+		if err != nil {
+			return nil, stepError("could not read an item", err)
+		}
+		text = strings.TrimSpace(text)
 		fmt.Printf("getItems: item: %s\n", text)
 		output[text] = itemElement
 	}
 	return output, nil
 }
 
-func (m *Mobius) GetItemsN(limit int) (map[string]*rod.Element, error) {
+func (m *Mobius) GetItemsN(ctx context.Context, limit int) (map[string]*rod.Element, error) {
+	page := m.page.Context(ctx)
+
 	output := map[string]*rod.Element{}
 
 	for {
 		originalLength := len(output)
-		newOutput, err := m.GetItems()
+		newOutput, err := m.GetItems(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -257,19 +351,21 @@ func (m *Mobius) GetItemsN(limit int) (map[string]*rod.Element, error) {
 			break
 		}
 
-		err = m.page.Mouse.Scroll(0, 500, 4)
+		err = page.Mouse.Scroll(0, 500, 4)
 		if err != nil {
 			fmt.Printf("Could not scroll: %v\n", err)
 		}
-		m.page.WaitDOMStable(1*time.Second, 10)
+		page.WaitDOMStable(1*time.Second, 10)
 	}
 
 	return output, nil
 }
 
-func (m *Mobius) SearchItems(searchText string) error {
+func (m *Mobius) SearchItems(ctx context.Context, searchText string) error {
 	fmt.Printf("SearchItems: %s\n", searchText)
 
+	page := m.page.Context(ctx)
+
 	//panic("oops")
 
 	t, err := time.Parse("Jan 2, 2006 3:04:05 PM", searchText)
@@ -277,10 +373,34 @@ func (m *Mobius) SearchItems(searchText string) error {
 		searchText = t.Format("20060102150405")
 	}
 
-	inputElement := m.page.MustElement("app-mobius-view-content-list mobius-content-list mobius-content-filter input")
-	inputElement.Type(slices.Repeat([]input.Key{input.Backspace}, 30)...)
-	inputElement.MustInput(searchText)
+	inputElement, err := page.Element("app-mobius-view-content-list mobius-content-list mobius-content-filter input")
+	if err != nil {
+		return stepError("search box not found", err)
+	}
+	err = inputElement.Type(slices.Repeat([]input.Key{input.Backspace}, 30)...)
+	if err != nil {
+		return stepError("could not clear the search box", err)
+	}
+	err = inputElement.Input(searchText)
+	if err != nil {
+		return stepError(fmt.Sprintf("could not search for %q", searchText), err)
+	}
+
+	page.WaitDOMStable(5*time.Second, 10)
+	return nil
+}
 
-	m.page.WaitDOMStable(5*time.Second, 10)
+// clickElement clicks on the element that matches the selector.
+//
+// The description is what the element is, such as "export button", for the error.
+func clickElement(page *rod.Page, selector string, description string) error {
+	element, err := page.Element(selector)
+	if err != nil {
+		return stepError(description+" not found", err)
+	}
+	err = element.Click(proto.InputMouseButtonLeft, 1)
+	if err != nil {
+		return stepError("could not click the "+description, err)
+	}
 	return nil
 }
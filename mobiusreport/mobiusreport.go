@@ -0,0 +1,257 @@
+// Package mobiusreport reads the Mobius reports in their original fixed-width print format.
+//
+// A print report is a series of pages.  Each page starts with a header that names the report (such as "DGL060")
+// and gives the context for the rows that follow, such as the "AS OF" date and the "DEPARTMENT".  A new department
+// is a division break.  The column headings sit directly on top of a ruler line made of dashes, and the ruler marks
+// where each column starts:
+//
+//	FUND  APPR   TYPE  DESCRIPTION               END DATE      AVAILABLE
+//	                                                               FUNDS
+//	----- ------ ----  ------------------------  --------  -------------
+//	100   10001  A     SALARIES                  06/30/25       1,000.00
+//	                                   FUND 100 TOTAL           1,000.00
+//
+// A line with nothing in its key column (such as the fund) and a label ending in "TOTAL" in its description column is a
+// subtotal (or the grand total), and is not a row of its own.  A row may well have "TOTAL" in its description.
+//
+// The values are handed to the csvloader package keyed by the column names of the CSV extract, so a row from a print
+// report is loaded into exactly the same model as a row from the CSV.
+package mobiusreport
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/tekkamanendless/cboc-tools/csvloader"
+)
+
+// Result is everything that was read from a report.
+type Result[T any] struct {
+	Records   []T
+	Lines     []int              // This is the line of the file that each record came from.
+	Rejects   []csvloader.Reject // These are the rows that could not be loaded.
+	Subtotals int                // This is the number of subtotal lines that were skipped.
+}
+
+// ErrWrongReport means that the file is not the kind of report that it was parsed as.
+var ErrWrongReport = errors.New("wrong report")
+
+// layout describes one of the reports.
+type layout struct {
+	reportID    string
+	columns     map[string]string // This maps the (normalized) column heading to the column in the CSV extract.
+	key         string            // This is the column (in the CSV extract) that every row has and that no subtotal has.
+	description string            // This is the column (in the CSV extract) that the subtotal labels are printed in.
+	context     []contextPattern
+}
+
+// contextPattern pulls a value out of the page headers.
+//
+// Each group in the pattern is saved as the matching column in the CSV extract.
+type contextPattern struct {
+	pattern *regexp.Regexp
+	columns []string
+}
+
+var (
+	asOfPattern        = regexp.MustCompile(`\bAS OF\s+(\d{1,2}/\d{1,2}/\d{2,4})\b`)
+	departmentPattern  = regexp.MustCompile(`\bDEPARTMENT:?\s+(\d+)\s+(.*?)\s*$`)
+	fiscalYearPattern  = regexp.MustCompile(`\bFISCAL YEAR:?\s+(\d+)\b`)
+	budgetRefPattern   = regexp.MustCompile(`\bBUDGET REF(?:ERENCE)?:?\s+(\d+)\b`)
+	acctPeriodPattern  = regexp.MustCompile(`\bACCOUNTING PERIOD:?\s+(\d+)\b`)
+	rulerPattern       = regexp.MustCompile(`^[\s-]*-{2,}[\s-]*$`)
+	subtotalPattern    = regexp.MustCompile(`(?i)\bTOTALS?$`)
+	endOfReportPattern = regexp.MustCompile(`(?i)\bEND OF REPORT\b`)
+)
+
+// column is where a column sits on the line.
+type column struct {
+	name  string // This is the column in the CSV extract.
+	start int
+	end   int // This is -1 for the last column, which runs to the end of the line.
+}
+
+// headerLines is how far into the file the report name has to show up.
+//
+// Anything else is not a print report at all, so there is no need to read the rest of it.
+const headerLines = 10
+
+// parse reads the report one line at a time.
+func parse[T any](r io.Reader, l layout) (*Result[T], error) {
+	// The report name is the first thing on the first line of each page, after the carriage control character.
+	reportPattern := regexp.MustCompile(`^\f?[ 0-9+-]?` + regexp.QuoteMeta(l.reportID) + `\b`)
+
+	var lines []string
+	var foundReport bool
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if reportPattern.MatchString(line) {
+			foundReport = true
+		}
+		lines = append(lines, line)
+		if !foundReport && len(lines) >= headerLines {
+			break
+		}
+	}
+	// A file that is not a report at all may well not have lines that the scanner can read, so that comes first.
+	if !foundReport {
+		return nil, fmt.Errorf("this is not a %s report: %w", l.reportID, ErrWrongReport)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read report: %w", err)
+	}
+
+	// The column headings are the lines right above each ruler, so find those first.
+	headingLines := map[int]bool{}
+	for i, line := range lines {
+		if !rulerPattern.MatchString(line) {
+			continue
+		}
+		for j := i - 1; j >= 0 && j >= i-2; j-- {
+			if strings.TrimSpace(lines[j]) == "" || isContext(lines[j], l) {
+				break
+			}
+			headingLines[j] = true
+		}
+	}
+
+	result := &Result[T]{}
+	context := map[string]string{}
+	var columns []column
+	inTable := false
+	for i, line := range lines {
+		lineNumber := i + 1
+
+		// A form feed or the report name starts a new page, and the rows do not start again until after the next ruler.
+		if strings.HasPrefix(line, "\f") || reportPattern.MatchString(line) {
+			inTable = false
+			line = strings.TrimPrefix(line, "\f")
+		}
+		if isContext(line, l) {
+			for _, c := range l.context {
+				match := c.pattern.FindStringSubmatch(line)
+				if match == nil {
+					continue
+				}
+				for g, name := range c.columns {
+					context[name] = strings.TrimSpace(match[g+1])
+				}
+			}
+			continue
+		}
+		if rulerPattern.MatchString(line) {
+			columns = parseColumns(lines, i, l)
+			inTable = true
+			continue
+		}
+		if !inTable || headingLines[i] || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if endOfReportPattern.MatchString(line) {
+			inTable = false
+			continue
+		}
+
+		values := map[string]string{}
+		for name, value := range context {
+			values[name] = value
+		}
+		for _, c := range columns {
+			values[c.name] = cell(line, c)
+		}
+		if values[l.key] == "" && subtotalPattern.MatchString(values[l.description]) {
+			result.Subtotals++
+			continue
+		}
+		record, reject, err := csvloader.Decode[T](values)
+		if err != nil {
+			return nil, err
+		}
+		if reject != nil {
+			reject.Line = lineNumber
+			result.Rejects = append(result.Rejects, *reject)
+			continue
+		}
+		result.Records = append(result.Records, record)
+		result.Lines = append(result.Lines, lineNumber)
+	}
+	return result, nil
+}
+
+// isContext returns true if the line is part of a page header that sets the context for the rows.
+func isContext(line string, l layout) bool {
+	for _, c := range l.context {
+		if c.pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseColumns works out the columns from the ruler on the given line and the headings above it.
+//
+// Each column runs from the start of its dashes to the start of the next column's dashes; numbers are right-aligned,
+// so they may start to the left of their dashes, but they never cross into the previous column.
+func parseColumns(lines []string, rulerIndex int, l layout) []column {
+	ruler := lines[rulerIndex]
+	type span struct{ start, end int }
+	var spans []span
+	for i := 0; i < len(ruler); i++ {
+		if ruler[i] != '-' {
+			continue
+		}
+		start := i
+		for i < len(ruler) && ruler[i] == '-' {
+			i++
+		}
+		spans = append(spans, span{start: start, end: i})
+	}
+
+	var columns []column
+	for s, sp := range spans {
+		start := sp.start
+		if s > 0 {
+			start = spans[s-1].end
+		}
+		end := -1
+		if s < len(spans)-1 {
+			end = spans[s+1].start
+		}
+
+		// The heading may be stacked over two lines.
+		var parts []string
+		for j := rulerIndex - 2; j < rulerIndex; j++ {
+			if j < 0 || strings.TrimSpace(lines[j]) == "" || isContext(lines[j], l) {
+				continue
+			}
+			part := strings.TrimSpace(cell(lines[j], column{start: sp.start, end: sp.end}))
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		heading := strings.Join(strings.Fields(strings.ToUpper(strings.Join(parts, " "))), " ")
+		name, ok := l.columns[heading]
+		if !ok {
+			continue
+		}
+		columns = append(columns, column{name: name, start: start, end: end})
+	}
+	return columns
+}
+
+// cell returns the part of the line that belongs to the column.
+func cell(line string, c column) string {
+	if c.start >= len(line) {
+		return ""
+	}
+	if c.end < 0 || c.end > len(line) {
+		return strings.TrimSpace(line[c.start:])
+	}
+	return strings.TrimSpace(line[c.start:c.end])
+}
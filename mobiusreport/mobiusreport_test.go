@@ -0,0 +1,186 @@
+package mobiusreport
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// openTestFile opens a file from the testdata directory.
+func openTestFile(t *testing.T, name string) *os.File {
+	t.Helper()
+
+	fileHandle, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatalf("Could not open %s: %v", name, err)
+	}
+	t.Cleanup(func() { fileHandle.Close() })
+	return fileHandle
+}
+
+// date returns midnight UTC on the given day.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDGL060(t *testing.T) {
+	result, err := ParseDGL060(openTestFile(t, "DGL060.txt"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every row gets the context from its page header.
+	header := databasemodel.MobiusDGL060{
+		AsOfDate:              date(2024, time.September, 30),
+		DepartmentID:          "95330000",
+		DepartmentDescription: "CHRISTINA SCHOOL DISTRICT",
+		FiscalYear:            2025,
+	}
+	row := func(fund, appr, appropriationType, description string, endDate time.Time, available, encumbered, current, prior, remaining databasemodel.Money) databasemodel.MobiusDGL060 {
+		record := header
+		record.Fund = fund
+		record.Appropriation = appr
+		record.AppropriationType = appropriationType
+		record.AppropriationDescription = description
+		record.EndDate = endDate
+		record.AvailableAmount = available
+		record.EncumberedAmount = encumbered
+		record.CurrentYearExpenses = current
+		record.PriorYearExpenses = prior
+		record.RemainingAmount = remaining
+		return record
+	}
+	expected := []databasemodel.MobiusDGL060{
+		// A trailing minus sign is a negative amount.
+		row("100", "10001", "A", "SALARIES", date(2025, time.June, 30), 100000, 10000, -5000, 0, 85000),
+		row("100", "10002", "A", "SUPPLIES", date(2025, time.June, 30), 250000, 0, 120000, 0, 130000),
+		row("250", "20001", "S", "TRANSPORTATION", date(2024, time.December, 31), 1234567, 100000, 200000, 34567, 900000),
+		// This row is on the second page.
+		row("300", "30001", "A", "MINOR CAPITAL IMPROVEMENTS", date(2026, time.June, 30), 40000, 0, 0, 0, 40000),
+	}
+	if len(result.Records) != len(expected) {
+		t.Fatalf("Expected %d records; got %d: %+v", len(expected), len(result.Records), result.Records)
+	}
+	for i, record := range result.Records {
+		if record != expected[i] {
+			t.Errorf("Record %d:\nexpected %+v\n     got %+v", i, expected[i], record)
+		}
+	}
+	expectedLines := []int{9, 10, 12, 25}
+	for i, line := range result.Lines {
+		if line != expectedLines[i] {
+			t.Errorf("Record %d: expected line %d; got %d.", i, expectedLines[i], line)
+		}
+	}
+	if len(result.Rejects) != 0 {
+		t.Errorf("Expected no rejects; got %+v", result.Rejects)
+	}
+	// There are two fund totals, a department total on each page, and the grand total.
+	if result.Subtotals != 5 {
+		t.Errorf("Expected 5 subtotals; got %d.", result.Subtotals)
+	}
+}
+
+func TestParseDGL114(t *testing.T) {
+	result, err := ParseDGL114(openTestFile(t, "DGL114.txt"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []databasemodel.MobiusDGL114{
+		{AsOfDate: date(2024, time.September, 30), DepartmentID: "95330000", DepartmentDescription: "CHRISTINA", BudgetYear: 2025, Fund: "100", Appropriation: "10001", AppropriationType: "A", RevenueAccount: "40000", RevenueAccountDescription: "TAXES", LocalFundsCurrent: 10000, LocalFundsYearToDate: 30000, StateFundsCurrent: 5000, StateFundsYearToDate: 15000},
+		// Parentheses are a negative amount.
+		{AsOfDate: date(2024, time.September, 30), DepartmentID: "95330000", DepartmentDescription: "CHRISTINA", BudgetYear: 2025, Fund: "100", Appropriation: "10001", AppropriationType: "A", RevenueAccount: "41000", RevenueAccountDescription: "TUITION", LocalFundsCurrent: -2500, LocalFundsYearToDate: 7500, StateFundsCurrent: 0, StateFundsYearToDate: 0},
+	}
+	if len(result.Records) != len(expected) {
+		t.Fatalf("Expected %d records; got %d: %+v", len(expected), len(result.Records), result.Records)
+	}
+	for i, record := range result.Records {
+		if record != expected[i] {
+			t.Errorf("Record %d:\nexpected %+v\n     got %+v", i, expected[i], record)
+		}
+	}
+	if len(result.Rejects) != 0 {
+		t.Errorf("Expected no rejects; got %+v", result.Rejects)
+	}
+	if result.Subtotals != 2 {
+		t.Errorf("Expected 2 subtotals; got %d.", result.Subtotals)
+	}
+}
+
+func TestParseDGL115(t *testing.T) {
+	result, err := ParseDGL115(openTestFile(t, "DGL115.txt"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []databasemodel.MobiusDGL115{
+		{DepartmentID: "95330000", DepartmentDescription: "CHRISTINA SCHOOL DISTRICT", FiscalYear: 2025, AccountPeriod: 3, Account: "55000", AccountDescription: "SUPPLIES", LocalFundsMonthToDate: 1000, StateFundsMonthToDate: 2000, TotalFundsMonthToDate: 3000, LocalFundsYearToDate: 10000, StateFundsYearToDate: 20000, TotalFundsYearToDate: 30000},
+		{DepartmentID: "95330000", DepartmentDescription: "CHRISTINA SCHOOL DISTRICT", FiscalYear: 2025, AccountPeriod: 3, Account: "51000", AccountDescription: "SALARIES", LocalFundsMonthToDate: 100000, StateFundsMonthToDate: 200000, TotalFundsMonthToDate: 300000, LocalFundsYearToDate: 300000, StateFundsYearToDate: 600000, TotalFundsYearToDate: 900000},
+	}
+	if len(result.Records) != len(expected) {
+		t.Fatalf("Expected %d records; got %d: %+v", len(expected), len(result.Records), result.Records)
+	}
+	for i, record := range result.Records {
+		if record != expected[i] {
+			t.Errorf("Record %d:\nexpected %+v\n     got %+v", i, expected[i], record)
+		}
+	}
+	if len(result.Rejects) != 0 {
+		t.Errorf("Expected no rejects; got %+v", result.Rejects)
+	}
+	if result.Subtotals != 1 {
+		t.Errorf("Expected 1 subtotal; got %d.", result.Subtotals)
+	}
+}
+
+func TestParseDescriptionWithTotal(t *testing.T) {
+	report := strings.Join([]string{
+		"1DGL115                                 STATE OF DELAWARE",
+		" RUN DATE 10/02/24                      ACCOUNT EXPENDITURES REPORT                                                  FISCAL YEAR 25",
+		" ACCOUNTING PERIOD 3",
+		" DEPARTMENT 95330000  CHRISTINA SCHOOL DISTRICT",
+		"",
+		" ACCOUNT   DESCRIPTION                             GF MTD          SF MTD       TOTAL MTD          GF YTD          SF YTD       TOTAL YTD",
+		" --------  ------------------------------  --------------  --------------  --------------  --------------  --------------  --------------",
+		" 55010     TOTAL QUALITY MANAGEMENT                  1.00            2.00            3.00            1.00            2.00            3.00",
+		" 55020     SCOREBOARD TOTAL                          1.00            2.00            3.00            1.00            2.00            3.00",
+		"           DEPARTMENT TOTAL                          2.00            4.00            6.00            2.00            4.00            6.00",
+	}, "\n")
+	result, err := ParseDGL115(strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("Expected 2 records; got %d: %+v", len(result.Records), result.Records)
+	}
+	if result.Records[0].AccountDescription != "TOTAL QUALITY MANAGEMENT" || result.Records[1].AccountDescription != "SCOREBOARD TOTAL" {
+		t.Errorf("Wrong descriptions: %q, %q", result.Records[0].AccountDescription, result.Records[1].AccountDescription)
+	}
+	if result.Subtotals != 1 {
+		t.Errorf("Expected 1 subtotal; got %d.", result.Subtotals)
+	}
+}
+
+func TestParseWrongReport(t *testing.T) {
+	rows := []struct {
+		name     string
+		contents string
+	}{
+		{name: "other report", contents: "1DGL060                                 STATE OF DELAWARE\n"},
+		{name: "mentions the report", contents: "Remember to download DGL115.\n"},
+		{name: "empty", contents: ""},
+		// A file that is not a report is given up on after its first few lines, even if they are too long to read.
+		{name: "binary", contents: strings.Repeat("\x00", 2*1024*1024)},
+	}
+	for _, row := range rows {
+		_, err := ParseDGL115(strings.NewReader(row.contents))
+		if !errors.Is(err, ErrWrongReport) {
+			t.Errorf("%s: expected ErrWrongReport; got %v", row.name, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package mobiusreport
+
+import (
+	"io"
+
+	"github.com/tekkamanendless/cboc-tools/databasemodel"
+)
+
+// dgl060Layout is the Appropriation Status report.
+var dgl060Layout = layout{
+	reportID: "DGL060",
+	columns: map[string]string{
+		"FUND":              "fund",
+		"APPR":              "appr",
+		"TYPE":              "type",
+		"DESCRIPTION":       "appr_descr",
+		"END DATE":          "end_date",
+		"AVAILABLE FUNDS":   "available_funds",
+		"ENCUMBRANCES":      "encumbrances",
+		"CURR YR EXPEN":     "curr_yr_expen",
+		"PRIOR YR EXPEN":    "prior_yr_expen",
+		"REMAIN SPEND AUTH": "remain_spend_auth",
+	},
+	key:         "fund",
+	description: "appr_descr",
+	context: []contextPattern{
+		{pattern: asOfPattern, columns: []string{"rpt_asof_date"}},
+		{pattern: departmentPattern, columns: []string{"dept_id", "dept_desc"}},
+		{pattern: fiscalYearPattern, columns: []string{"fy"}},
+	},
+}
+
+// dgl114Layout is the Revenue report.
+var dgl114Layout = layout{
+	reportID: "DGL114",
+	columns: map[string]string{
+		"FUND":        "fund",
+		"APPR":        "apprcode",
+		"TYPE":        "apprtype",
+		"REV ACCT":    "revaccount",
+		"DESCRIPTION": "revdescr",
+		"GF CURRENT":  "gf_current",
+		"GF YTD":      "gf_ytd",
+		"SF CURRENT":  "sf_current",
+		"SF YTD":      "sf_ytd",
+	},
+	key:         "fund",
+	description: "revdescr",
+	context: []contextPattern{
+		{pattern: asOfPattern, columns: []string{"rptasofdate"}},
+		{pattern: departmentPattern, columns: []string{"deptid", "deptdesc"}},
+		{pattern: budgetRefPattern, columns: []string{"budref"}},
+	},
+}
+
+// dgl115Layout is the Account Expenditures report.
+var dgl115Layout = layout{
+	reportID: "DGL115",
+	columns: map[string]string{
+		"ACCOUNT":     "account",
+		"DESCRIPTION": "acct_descr",
+		"GF MTD":      "gf_mtd",
+		"SF MTD":      "sf_mtd",
+		"TOTAL MTD":   "totl_mtd",
+		"GF YTD":      "gf_ytd",
+		"SF YTD":      "sf_ytd",
+		"TOTAL YTD":   "totl_ytd",
+	},
+	key:         "account",
+	description: "acct_descr",
+	context: []contextPattern{
+		{pattern: departmentPattern, columns: []string{"deptid", "dept_descr"}},
+		{pattern: fiscalYearPattern, columns: []string{"fy"}},
+		{pattern: acctPeriodPattern, columns: []string{"acct_period"}},
+	},
+}
+
+// ParseDGL060 reads a DGL060 (Appropriation Status) print report.
+//
+// The division is not part of the report, so it is left for the caller to fill in.
+func ParseDGL060(r io.Reader) (*Result[databasemodel.MobiusDGL060], error) {
+	return parse[databasemodel.MobiusDGL060](r, dgl060Layout)
+}
+
+// ParseDGL114 reads a DGL114 (Revenue) print report.
+//
+// The division is not part of the report, so it is left for the caller to fill in.
+func ParseDGL114(r io.Reader) (*Result[databasemodel.MobiusDGL114], error) {
+	return parse[databasemodel.MobiusDGL114](r, dgl114Layout)
+}
+
+// ParseDGL115 reads a DGL115 (Account Expenditures) print report.
+//
+// The division is not part of the report, so it is left for the caller to fill in.
+func ParseDGL115(r io.Reader) (*Result[databasemodel.MobiusDGL115], error) {
+	return parse[databasemodel.MobiusDGL115](r, dgl115Layout)
+}
@@ -0,0 +1,69 @@
+// Package scrape has what the packages that drive the state's web sites have in common.
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// StepError is returned when one of the steps of driving a web site fails.
+//
+// The step says what went wrong in words that make sense to a person, such as "login form not found" or "format option
+// missing", so that the caller can decide whether to retry, skip that one report, or give up.
+type StepError struct {
+	Site string // This is the site (or part of a site), such as "mobius".
+	Step string
+	Err  error // This is the underlying error, if there is one.
+}
+
+func (e *StepError) Error() string {
+	if e.Err == nil {
+		return e.Site + ": " + e.Step
+	}
+	return e.Site + ": " + e.Step + ": " + e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// WaitDownload gets ready for the browser to download a file; this must be called before whatever starts the download.
+//
+// The returned function waits for the download to finish and returns its contents.  It gives up when the context is
+// done.
+func WaitDownload(ctx context.Context, browser *rod.Browser) func() ([]byte, error) {
+	directory := filepath.Join(os.TempDir(), "rod", "downloads")
+	wait := browser.Context(ctx).WaitDownload(directory)
+
+	return func() ([]byte, error) {
+		info := wait()
+		// Rod stops waiting when the context is done, whether or not the download has finished.
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if info == nil {
+			return nil, fmt.Errorf("the download never started")
+		}
+		filename := filepath.Join(directory, info.GUID)
+		defer os.Remove(filename)
+		return os.ReadFile(filename)
+	}
+}
+
+// Sleep waits for the given duration, or until the context is done.
+func Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}